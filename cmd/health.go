@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"todo-backend/internal/service"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/health"
+	"todo-backend/pkg/lockout"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/ws"
+)
+
+// registerHealthChecks 注册 /readyz 检查的组件：数据库连接、迁移版本、WebSocket 管理器和后台任务
+func registerHealthChecks(checker *health.Checker, migrator *migrate.Migrator) {
+	checker.Register("database", func(ctx context.Context) (interface{}, error) {
+		sqlDB, err := db.Store.SQL()
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		return map[string]int{"open_connections": stats.OpenConnections, "in_use": stats.InUse}, nil
+	})
+
+	// 另一个实例回滚了迁移，或新迁移尚未执行时，当前版本的程序可能读写不存在的表和列
+	checker.Register("migrations", func(ctx context.Context) (interface{}, error) {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var version, latest int64
+		pending := 0
+		for _, status := range statuses {
+			if status.AppliedAt != nil && status.Version > version {
+				version = status.Version
+			}
+			if status.Unknown {
+				continue
+			}
+			latest = max(latest, status.Version)
+			if status.AppliedAt == nil {
+				pending++
+			}
+		}
+		detail := map[string]int64{"version": version, "latest": latest}
+		if pending > 0 {
+			return detail, fmt.Errorf("pending migrations: %d", pending)
+		}
+		return detail, nil
+	})
+
+	checker.Register("websocket", func(ctx context.Context) (interface{}, error) {
+		if err := ws.Manager.Ping(ctx); err != nil {
+			return nil, err
+		}
+		return map[string]int{"connections": ws.Manager.Stats().Connections}, nil
+	})
+
+	checker.Register("lockout_janitor", lockout.JanitorCheck())
+	checker.Register("tombstone_purge", service.TombstonePurgeCheck())
+}
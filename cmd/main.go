@@ -1,50 +1,285 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 
 	"todo-backend/internal/api/handler"
-	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/api/openapi"
+	"todo-backend/internal/service"
+	"todo-backend/pkg/config"
 	"todo-backend/pkg/db"
+	"todo-backend/pkg/lockout"
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/metrics"
+	"todo-backend/pkg/oauth"
+	"todo-backend/pkg/tracing"
+	"todo-backend/pkg/utils"
 	"todo-backend/pkg/ws"
+	"todo-backend/pkg/ws/backplane"
 )
 
+var log = logging.For(logging.App)
+
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal(log, "Invalid configuration", "error", err)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			logging.Fatal(log, "Failed to print configuration", "error", err)
+		}
+		return
+	}
+	initLogging(cfg.Log)
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		Endpoint:    cfg.Tracing.Endpoint,
+		File:        cfg.Tracing.File,
+	})
+	if err != nil {
+		logging.Fatal(log, "Failed to initialize tracing", "error", err)
+	}
+	utils.InitJWT(cfg.JWT.Secret, cfg.JWT.AccessTokenTTL.Std())
+	handler.TOTPIssuer = cfg.TOTP.Issuer
+
+	// 收到 SIGINT/SIGTERM 后开始优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 后台任务在 HTTP 请求全部处理完之后才停止
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	var jobs sync.WaitGroup
+	runJob := func(job func(context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(jobsCtx)
+		}()
 	}
 
 	// 初始化数据库
-	db.InitDB()
+	db.InitDB(cfg.Database.Driver, cfg.Database.DSN(), dbOptions(cfg.Database))
+	migrateOnStartup(ctx, cfg.Database)
+
+	// 加载第三方登录提供方
+	oauth.InitProviders(oauthProviders(cfg.OAuth), cfg.OAuth.CallbackBaseURL)
+
+	// 登录失败锁定
+	lockout.Init(db.Store.LoginAttempts(), cfg.Login.MaxFailures, cfg.Login.IPMaxFailures, cfg.Login.LockoutBase.Std(), cfg.Login.LockoutMax.Std())
+	runJob(lockout.RunJanitor)
+
+	// 彻底删除超过保留时长的已删除任务
+	service.TombstoneRetention = cfg.Sync.TombstoneRetention.Std()
+	runJob(service.RunTombstonePurge)
 
 	// 初始化 WebSocket 管理器
 	ws.InitManager()
+	ws.Manager.Policy = ws.SlowConsumerPolicy(cfg.WebSocket.SlowConsumerPolicy)
+	ws.Manager.SendBuffer = cfg.WebSocket.SendBuffer
+	ws.Manager.BufferLimit = cfg.WebSocket.BufferLimit
+	handler.RegisterCommands(ws.Manager)
+	runJob(ws.Manager.Run)
+
+	// Prometheus 指标
+	if cfg.Metrics.Enabled {
+		sqlDB, err := db.Store.SQL()
+		if err != nil {
+			logging.Fatal(log, "Failed to get database instance", "error", err)
+		}
+		metrics.RegisterDB(sqlDB)
+		metrics.RegisterWebSocket(ws.Manager, cfg.WebSocket.SendBuffer)
+	}
+
+	// 就绪检查
+	registerHealthChecks(handler.Readiness, newMigrator(cfg.Database.Driver))
+
+	// 多实例部署时通过 backplane 把事件同步到其他实例
+	bp, err := newBackplane(cfg.WebSocket.Backplane, cfg.Database.DSN())
+	if err != nil {
+		logging.Fatal(log, "Failed to initialize WebSocket backplane", "error", err)
+	}
+	if bp != nil {
+		if err := ws.Manager.UseBackplane(jobsCtx, bp); err != nil {
+			logging.Fatal(log, "Failed to subscribe to WebSocket backplane", "error", err)
+		}
+	}
+
+	// 接口文档
+	if err := openapi.Init(); err != nil {
+		logging.Fatal(log, "Failed to load API specification", "error", err)
+	}
+
+	// 访问日志和 panic 由 AccessLog、Recovery 以结构化日志输出，未设置 GIN_MODE 时关闭 gin 的调试输出
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := newRouter(cfg)
+
+	// 新增或修改路由时需同步更新 internal/api/openapi/openapi.yaml
+	if missing, stale := openapi.CheckRoutes(r.Routes()); len(missing) > 0 || len(stale) > 0 {
+		log.Warn("API specification is out of sync with the routes", "undocumented", missing, "not_routed", stale)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Std(),
+		ReadTimeout:       cfg.Server.ReadTimeout.Std(),
+		WriteTimeout:      cfg.Server.WriteTimeout.Std(), // SSE 连接在处理函数中单独取消写超时
+		IdleTimeout:       cfg.Server.IdleTimeout.Std(),
+		ErrorLog:          logging.StdLogger(logging.HTTP, slog.LevelWarn),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal(log, "Server failed", "error", err)
+		}
+	}()
+	log.Info("Server listening", "addr", srv.Addr)
+
+	// 管理端口只提供 /metrics 和带组件详情的 /readyz，不经过 API 的中间件
+	var adminSrv *http.Server
+	if cfg.Metrics.Enabled {
+		admin := gin.New()
+		admin.GET("/metrics", gin.WrapH(metrics.Handler()))
+		admin.GET("/readyz", handler.ReadyzDetail)
+		adminSrv = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           admin,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Std(),
+			ErrorLog:          logging.StdLogger(logging.HTTP, slog.LevelWarn),
+		}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Fatal(log, "Admin server failed", "error", err)
+			}
+		}()
+		log.Info("Admin server listening", "addr", adminSrv.Addr)
+	}
+
+	<-ctx.Done()
+	// 再次收到信号时直接退出
+	stop()
+	log.Info("Shutting down")
 
-	r := gin.Default()
+	// /readyz 立即返回 503，等待负载均衡摘除本实例后再断开连接
+	handler.Readiness.ShutDown()
+	if delay := cfg.Server.ShutdownDelay.Std(); delay > 0 {
+		log.Info("Waiting before shutdown", "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Std())
+	defer cancel()
 
-	// 公开路由
-	r.POST("/api/register", handler.Register)
-	r.POST("/api/login", handler.Login)
+	// 先让 WebSocket/SSE 客户端断开，再停止接收新请求并等待进行中的请求完成
+	if err := ws.Manager.Shutdown(shutdownCtx); err != nil {
+		log.Warn("WebSocket clients did not disconnect in time", "error", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Warn("HTTP server did not shut down cleanly", "error", err)
+	}
+	if adminSrv != nil {
+		adminSrv.Shutdown(shutdownCtx)
+	}
 
-	// 需要认证的路由
-	auth := r.Group("/api")
-	auth.Use(middleware.AuthMiddleware())
-	{
-		// Todo 路由
-		auth.POST("/todos", handler.CreateTodo)
-		auth.GET("/todos", handler.GetTodos)
-		auth.PUT("/todos/:id", handler.UpdateTodo)
-		auth.DELETE("/todos/:id", handler.DeleteTodo)
-		auth.PATCH("/todos/:id/toggle", handler.ToggleTodo)
+	cancelJobs()
+	done := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Warn("Background jobs did not stop in time")
+	}
 
-		// WebSocket 路由
-		auth.GET("/ws", handler.HandleWebSocket)
+	if bp != nil {
+		bp.Close()
 	}
+	if err := db.Close(); err != nil {
+		log.Error("Failed to close database", "error", err)
+	}
+	// 最后导出剩余的 span，包括关闭过程中的请求和 SQL
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn("Failed to flush traces", "error", err)
+	}
+	log.Info("Server stopped")
+}
+
+// initLogging 按配置设置日志格式和各子系统的级别（配置已校验）
+func initLogging(cfg config.LogConfig) {
+	level, _ := logging.ParseLevel(cfg.Level)
+	levels, _ := cfg.SubsystemLevels()
+	logging.Init(os.Stderr, cfg.Format, level, levels)
+}
+
+// oauthProviders 已启用的提供方的设置
+func oauthProviders(cfg config.OAuthConfig) []oauth.ProviderConfig {
+	providers := make([]oauth.ProviderConfig, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		client := cfg.Client(name)
+		providers = append(providers, oauth.ProviderConfig{
+			Name:         name,
+			Issuer:       client.Issuer,
+			DiscoveryURL: client.DiscoveryURL,
+			ClientID:     client.ClientID,
+			ClientSecret: client.ClientSecret,
+			RedirectURL:  client.RedirectURL,
+			Scopes:       client.Scopes,
+			AuthURL:      client.AuthURL,
+			TokenURL:     client.TokenURL,
+			UserInfoURL:  client.UserInfoURL,
+			EmailsURL:    client.EmailsURL,
+		})
+	}
+	return providers
+}
+
+// dbOptions 数据库连接池和语句超时设置
+func dbOptions(cfg config.DatabaseConfig) db.Options {
+	return db.Options{
+		MaxOpenConns:     cfg.MaxOpenConns,
+		MaxIdleConns:     cfg.MaxIdleConns,
+		ConnMaxLifetime:  cfg.ConnMaxLifetime.Std(),
+		ConnMaxIdleTime:  cfg.ConnMaxIdleTime.Std(),
+		StatementTimeout: cfg.StatementTimeout.Std(),
+	}
+}
 
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal(err)
+// newBackplane 根据 WS_BACKPLANE 选择实现：postgres（默认）、memory（单实例）或 none
+func newBackplane(kind, dsn string) (ws.Backplane, error) {
+	switch kind {
+	case "", "postgres":
+		return backplane.NewPostgres(context.Background(), dsn)
+	case "memory":
+		return backplane.NewMemoryHub().Backplane(), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown backplane %q", kind)
 	}
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"todo-backend/pkg/config"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/migrate"
+)
+
+const migrateUsage = `usage: todo-backend migrate <command> [flags]
+
+commands:
+  up              apply all pending migrations
+  down [N|all]    roll back the last N migrations (default 1)
+  status          list migrations and whether they are applied
+  create <name>   add empty NNNN_<name>.up.sql/.down.sql files to ` + migrations.Dir + `/<driver>
+
+flags are the same as for the server (--config, --database.url, ...)`
+
+// runMigrate 处理 migrate 子命令
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+
+	// create 只生成文件，不需要配置和数据库
+	if command == "create" {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: todo-backend migrate create <name>")
+			os.Exit(2)
+		}
+		dirs := make([]string, len(migrations.Drivers))
+		for i, driver := range migrations.Drivers {
+			dirs[i] = filepath.Join(migrations.Dir, driver)
+		}
+		files, err := migrate.Create(dirs, args[0])
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		if err != nil {
+			logging.Fatal(log, "Failed to create migration", "error", err)
+		}
+		return
+	}
+
+	steps := 1
+	if command == "down" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] == "all" {
+			steps = int(^uint(0) >> 1)
+		} else if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+			steps = n
+		} else {
+			fmt.Fprintf(os.Stderr, "migrate down: %q is not a positive number\n", args[0])
+			os.Exit(2)
+		}
+		args = args[1:]
+	}
+
+	cfg, _, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal(log, "Invalid configuration", "error", err)
+	}
+	initLogging(cfg.Log)
+	db.InitDB(cfg.Database.Driver, cfg.Database.DSN(), dbOptions(cfg.Database))
+	defer db.Close()
+
+	m := newMigrator(cfg.Database.Driver)
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			logging.Fatal(log, "Migration failed", "error", err)
+		}
+		log.Info("Applied migrations", "count", len(applied))
+	case "down":
+		rolledBack, err := m.Down(ctx, steps)
+		if err != nil {
+			logging.Fatal(log, "Migration failed", "error", err)
+		}
+		log.Info("Rolled back migrations", "count", len(rolledBack))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			logging.Fatal(log, "Migration failed", "error", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				applied += " (not in this build)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// migrateOnStartup 按 DB_MIGRATE 执行迁移，或在表结构落后时拒绝启动
+func migrateOnStartup(ctx context.Context, cfg config.DatabaseConfig) {
+	m := newMigrator(cfg.Driver)
+	if cfg.Migrate == "up" {
+		applied, err := m.Up(ctx)
+		if err != nil {
+			logging.Fatal(log, "Failed to migrate database", "error", err)
+		}
+		log.Info("Database migration completed", "applied", len(applied))
+		return
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		logging.Fatal(log, "Failed to check database schema version", "error", err)
+	}
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, migration := range pending {
+			names[i] = migration.String()
+		}
+		logging.Fatal(log, "Database schema is behind. Run `todo-backend migrate up` or set DB_MIGRATE=up",
+			"pending", strings.Join(names, ", "))
+	}
+}
+
+func newMigrator(driver string) *migrate.Migrator {
+	sqlDB, err := db.Store.SQL()
+	if err != nil {
+		logging.Fatal(log, "Failed to get database instance", "error", err)
+	}
+	fsys, err := migrations.For(driver)
+	if err != nil {
+		logging.Fatal(log, "Failed to load migrations", "error", err)
+	}
+	m, err := migrate.New(sqlDB, driver, fsys)
+	if err != nil {
+		logging.Fatal(log, "Failed to load migrations", "error", err)
+	}
+	return m
+}
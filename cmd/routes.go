@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/swaggest/swgui/v5emb"
+
+	"todo-backend/internal/api/handler"
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/api/openapi"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/config"
+)
+
+// newRouter 注册中间件和全部 API 路由。openapi.Init 需先调用；
+// 新增或修改路由时需同步更新 internal/api/openapi/openapi.yaml，routes_test 会检查两者一致
+func newRouter(cfg *config.Config) *gin.Engine {
+	r := gin.New()
+	// 探针在中间件之前注册，不记录访问日志、指标和链路
+	r.GET("/healthz", handler.Healthz)
+	r.GET("/readyz", handler.Readyz)
+	r.Use(middleware.RequestID(), middleware.Tracing(cfg.Tracing.ServiceName), middleware.AccessLog(), middleware.Recovery())
+	if cfg.Metrics.Enabled {
+		r.Use(middleware.Metrics())
+	}
+	r.Use(middleware.CORS(cfg.CORS.AllowedOrigins, cfg.CORS.AllowCredentials, cfg.CORS.MaxAge.Std()))
+	routeTimeouts, _ := cfg.Database.StatementTimeouts() // 已在 config.Load 中校验
+	r.Use(middleware.StatementTimeouts(routeTimeouts))
+	if cfg.OpenAPI.Validate {
+		r.Use(middleware.ValidateOpenAPI())
+	}
+
+	// 接口文档：OpenAPI（REST）和 AsyncAPI（WebSocket/SSE 事件）
+	r.GET("/api/openapi.json", openapi.ServeOpenAPI)
+	r.GET("/api/asyncapi.json", openapi.ServeAsyncAPI)
+	if cfg.OpenAPI.Docs {
+		r.GET("/api/docs/*any", gin.WrapH(v5emb.New("Todo API", "/api/openapi.json", "/api/docs/")))
+	}
+
+	// 公开路由
+	r.POST("/api/register", handler.Register)
+	r.POST("/api/login", handler.Login)
+	r.POST("/api/login/2fa", handler.LoginTwoFactor)
+
+	// 第三方登录
+	r.GET("/api/oauth/providers", handler.ListOAuthProviders)
+	r.GET("/api/oauth/:provider/login", handler.OAuthLogin)
+	r.GET("/api/oauth/:provider/callback", handler.OAuthCallback)
+
+	// 需要认证的路由
+	auth := r.Group("/api")
+	auth.Use(middleware.AuthMiddleware())
+	{
+		// Todo 路由（Personal Access Token 需要对应的权限范围）
+		todosRead := middleware.RequireScope(model.ScopeTodosRead)
+		todosWrite := middleware.RequireScope(model.ScopeTodosWrite)
+		auth.POST("/todos", todosWrite, handler.CreateTodo)
+		auth.GET("/todos", todosRead, handler.GetTodos)
+		auth.PUT("/todos/:id", todosWrite, handler.UpdateTodo)
+		auth.DELETE("/todos/:id", todosWrite, handler.DeleteTodo)
+		auth.PATCH("/todos/:id/toggle", todosWrite, handler.ToggleTodo)
+
+		// 主题（清单、todo）的在线状态快照
+		auth.GET("/presence/:topic", todosRead, handler.GetPresence)
+
+		// 账号安全相关路由只允许登录会话访问
+		account := auth.Group("", middleware.RequireSession())
+		{
+			// 两步验证路由
+			account.POST("/2fa/setup", handler.SetupTwoFactor)
+			account.POST("/2fa/confirm", handler.ConfirmTwoFactor)
+			account.POST("/2fa/disable", handler.DisableTwoFactor)
+			account.POST("/2fa/recovery-codes", handler.RegenerateRecoveryCodes)
+
+			// 第三方身份关联
+			account.GET("/identities", handler.ListIdentities)
+			account.DELETE("/identities/:id", handler.DeleteIdentity)
+
+			// Personal Access Token 管理
+			account.POST("/tokens", handler.CreateToken)
+			account.GET("/tokens", handler.ListTokens)
+			account.DELETE("/tokens/:id", handler.RevokeToken)
+
+			// 会话与设备管理
+			account.GET("/sessions", handler.ListSessions)
+			account.DELETE("/sessions/:id", handler.RevokeSession)
+			account.POST("/sessions/revoke-others", handler.RevokeOtherSessions)
+		}
+
+		// WebSocket 路由
+		auth.GET("/ws", handler.HandleWebSocket)
+	}
+
+	// SSE 路由：EventSource 无法设置请求头，允许通过 access_token 查询参数认证
+	r.GET("/api/events", middleware.QueryToken(), middleware.AuthMiddleware(), middleware.RequireScope(model.ScopeTodosRead), handler.HandleEvents)
+
+	return r
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"todo-backend/internal/api/openapi"
+	"todo-backend/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesMatchOpenAPI 路由与 internal/api/openapi/openapi.yaml 中的接口一一对应
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := openapi.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	missing, stale := openapi.CheckRoutes(newRouter(config.Default()).Routes())
+	for _, route := range missing {
+		t.Errorf("route %s is not documented in openapi.yaml", route)
+	}
+	for _, route := range stale {
+		t.Errorf("openapi.yaml documents %s, which is not routed", route)
+	}
+}
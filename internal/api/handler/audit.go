@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/lockout"
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+var authLog = logging.For(logging.Auth)
+
+// recordAuthEvent 写入认证审计记录，失败只记日志，不影响登录流程
+func recordAuthEvent(c *gin.Context, event string, userID *uint, email string) {
+	record := model.AuthEvent{
+		UserID:    userID,
+		Email:     strings.ToLower(email),
+		Event:     event,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	metrics.AuthEvents.WithLabelValues(event).Inc()
+	if err := db.Store.AuthEvents().Create(c.Request.Context(), &record); err != nil {
+		authLog.ErrorContext(c.Request.Context(), "Failed to record auth event", "event", event, "error", err)
+	}
+}
+
+// loginLocked 账号或来源 IP 被锁定时返回 429 和 Retry-After；无法读取失败计数时同样中止请求
+func loginLocked(c *gin.Context, email string) bool {
+	ctx, now := c.Request.Context(), time.Now()
+	wait, err := lockout.Accounts.Check(ctx, strings.ToLower(email), now)
+	if err != nil {
+		middleware.Error(c, err, "Failed to check login attempts")
+		return true
+	}
+	ipWait, err := lockout.IPs.Check(ctx, c.ClientIP(), now)
+	if err != nil {
+		middleware.Error(c, err, "Failed to check login attempts")
+		return true
+	}
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait == 0 {
+		return false
+	}
+
+	recordAuthEvent(c, model.AuthEventLoginLocked, nil, email)
+	setRetryAfter(c, wait)
+	middleware.Abort(c, http.StatusTooManyRequests, CodeTooManyAttempts, "Too many failed login attempts, please try again later")
+	return true
+}
+
+// loginFailed 记录一次失败尝试；触发锁定时附带 Retry-After。计数写入失败只记日志，请求照常以失败响应
+func loginFailed(c *gin.Context, event string, userID *uint, email string) {
+	ctx, now := c.Request.Context(), time.Now()
+	wait, err := lockout.Accounts.Failure(ctx, strings.ToLower(email), now)
+	if err != nil {
+		authLog.ErrorContext(ctx, "Failed to record login failure", "error", err)
+	}
+	ipWait, err := lockout.IPs.Failure(ctx, c.ClientIP(), now)
+	if err != nil {
+		authLog.ErrorContext(ctx, "Failed to record login failure", "error", err)
+	}
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		setRetryAfter(c, wait)
+	}
+
+	recordAuthEvent(c, event, userID, email)
+}
+
+// loginSucceeded 清除账号的失败计数。IP 计数不清除，避免攻击者用自己的账号登录来重置
+func loginSucceeded(c *gin.Context, event string, user *model.User) {
+	if err := lockout.Accounts.Success(c.Request.Context(), strings.ToLower(user.Email)); err != nil {
+		authLog.ErrorContext(c.Request.Context(), "Failed to reset login failures", "error", err)
+	}
+	recordAuthEvent(c, event, &user.ID, user.Email)
+}
+
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
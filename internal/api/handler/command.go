@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+
+	"todo-backend/internal/model"
+	"todo-backend/internal/service"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// WebSocket 命令类型
+const (
+	CommandCreateTodo = "create_todo"
+	CommandUpdateTodo = "update_todo"
+	CommandToggleTodo = "toggle_todo"
+	CommandDeleteTodo = "delete_todo"
+)
+
+type todoIDCommand struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+type reauthCommand struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type updateTodoCommand struct {
+	ID uint `json:"id" binding:"required"`
+	model.TodoCreate
+}
+
+// RegisterCommands 注册 WebSocket 命令和订阅权限检查，与对应的 REST 接口共用 service 中的逻辑
+func RegisterCommands(m *ws.WSManager) {
+	m.Authorize = func(c *ws.Client, kind string, id uint) error {
+		return service.AuthorizeTopic(c.Context(), c.ID, kind, id)
+	}
+	m.Validate = validateConnections
+	m.Handle(ws.TypeReauth, reauthenticate)
+
+	m.Handle(CommandCreateTodo, func(c *ws.Client, data json.RawMessage) (interface{}, error) {
+		var input model.TodoCreate
+		if err := bindCommand(data, &input); err != nil {
+			return nil, err
+		}
+		return todoResult(service.CreateTodo(c.Context(), c.ID, input))
+	})
+
+	m.Handle(CommandUpdateTodo, func(c *ws.Client, data json.RawMessage) (interface{}, error) {
+		var input updateTodoCommand
+		if err := bindCommand(data, &input); err != nil {
+			return nil, err
+		}
+		return todoResult(service.UpdateTodo(c.Context(), c.ID, input.ID, input.TodoCreate))
+	})
+
+	m.Handle(CommandToggleTodo, func(c *ws.Client, data json.RawMessage) (interface{}, error) {
+		var input todoIDCommand
+		if err := bindCommand(data, &input); err != nil {
+			return nil, err
+		}
+		return todoResult(service.ToggleTodo(c.Context(), c.ID, input.ID))
+	})
+
+	m.Handle(CommandDeleteTodo, func(c *ws.Client, data json.RawMessage) (interface{}, error) {
+		var input todoIDCommand
+		if err := bindCommand(data, &input); err != nil {
+			return nil, err
+		}
+		return todoResult(service.DeleteTodo(c.Context(), c.ID, input.ID))
+	})
+}
+
+// bindCommand 解析命令数据并使用与 ShouldBindJSON 相同的校验规则
+func bindCommand(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return &ws.CommandError{Code: ws.ErrCodeValidationFailed, Message: "data is required"}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &ws.CommandError{Code: ws.ErrCodeValidationFailed, Message: err.Error()}
+	}
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		return &ws.CommandError{Code: ws.ErrCodeValidationFailed, Message: err.Error()}
+	}
+	return nil
+}
+
+// todoResult ack 中返回操作后的 todo
+func todoResult(event service.Event, err error) (interface{}, error) {
+	if errors.Is(err, service.ErrTodoNotFound) {
+		return nil, &ws.CommandError{Code: CodeTodoNotFound, Message: "Todo not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event.Data, nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/service"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 接口特有的错误码，通用错误码见 middleware.Code*；WebSocket 命令的错误使用同样的错误码
+const (
+	CodeTodoNotFound        = "todo_not_found"
+	CodeTokenNotFound       = "token_not_found"
+	CodeSessionNotFound     = "session_not_found"
+	CodeIdentityNotFound    = "identity_not_found"
+	CodeUserNotFound        = "user_not_found"
+	CodeProviderNotFound    = "provider_not_found"
+	CodeEmailTaken          = "email_taken"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeTooManyAttempts     = "too_many_attempts"
+	CodeLastSignInMethod    = "last_sign_in_method"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeOAuthDenied         = "oauth_denied"
+	CodeOAuthFailed         = "oauth_failed"
+	CodeInvalidOAuthState   = "invalid_oauth_state"
+	CodeEmailNotVerified    = "email_not_verified"
+	CodeTwoFactorEnabled    = "two_factor_enabled"
+	CodeTwoFactorNotEnabled = "two_factor_not_enabled"
+	CodeTwoFactorNotStarted = "two_factor_setup_not_started"
+	CodeInvalidCode         = "invalid_code"
+	CodeInvalidPassword     = "invalid_password"
+	CodeInvalidChallenge    = "invalid_challenge"
+)
+
+func init() {
+	middleware.MapError(service.ErrTodoNotFound, http.StatusNotFound, CodeTodoNotFound, "Todo not found")
+}
+
+// invalidID 路径中的 ID 不是正整数
+func invalidID(c *gin.Context) {
+	middleware.ValidationFailed(c, middleware.FieldError{Field: "id", Code: "type", Message: "must be a positive integer"})
+}
+
+// topicError 写出主题授权失败的响应：无权访问（如他人的 user 主题）为 403，主题不存在为 404，
+// 其他 *ws.CommandError 为 400，其余错误为 500
+func topicError(c *gin.Context, err error, message string) {
+	var cmdErr *ws.CommandError
+	if !errors.As(err, &cmdErr) {
+		middleware.Error(c, err, message)
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch cmdErr.Code {
+	case ws.ErrCodeForbidden:
+		status = http.StatusForbidden
+	case ws.ErrCodeTopicNotFound:
+		status = http.StatusNotFound
+	}
+	middleware.Abort(c, status, cmdErr.Code, cmdErr.Message)
+}
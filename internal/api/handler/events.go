@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxEventTopics SSE 连接通过 topics 参数最多订阅的主题数
+const maxEventTopics = 100
+
+// HandleEvents 以 Server-Sent Events 推送与 WebSocket 相同的事件，供无法建立 WebSocket 的客户端使用。
+// 默认订阅自己的 user 主题，可通过 topics=todo:1,todo:2 追加订阅；
+// 断线重连时浏览器会带上 Last-Event-ID，服务端补发期间的事件
+func HandleEvents(c *gin.Context) {
+	userID := c.GetUint("userID")
+	client := ws.NewClient(userID, c.GetUint("sessionID"), nil)
+	client.TokenID = c.GetUint("tokenID")
+	// SSE 无法发送 reauth，Token 到期后收到 close 事件，客户端换新 Token 重连即可
+	client.ExpiresAt = c.GetTime("tokenExpiresAt")
+	client.SetContext(context.WithoutCancel(c.Request.Context()))
+
+	var topics []string
+	if raw := c.Query("topics"); raw != "" {
+		topics = strings.Split(raw, ",")
+	}
+	if len(topics) > maxEventTopics {
+		middleware.Abort(c, http.StatusBadRequest, ws.ErrCodeTooManyTopics, "Too many topics")
+		return
+	}
+	for _, topic := range topics {
+		kind, id, err := ws.ParseTopic(topic)
+		if err != nil {
+			middleware.Abort(c, http.StatusBadRequest, ws.ErrCodeInvalidTopic, "Invalid topic: "+topic)
+			return
+		}
+		if err := ws.Manager.Authorize(client, kind, id); err != nil {
+			topicError(c, err, "Failed to subscribe")
+			return
+		}
+	}
+
+	// EventSource 自动重连时使用请求头，手动重连的客户端可以用查询参数
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+	c.Status(http.StatusOK)
+	// 事件流长期保持，不受服务端写超时限制
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	current := ws.Manager.Connect(client, topics, lastEventID)
+	defer func() {
+		ws.Manager.Unregister <- client
+	}()
+
+	client.EventPump(c.Request.Context(), c.Writer, c.Writer.Flush, current)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+
+	"todo-backend/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness /readyz 执行的组件检查，由 main 在启动时注册
+var Readiness = health.NewChecker()
+
+// Healthz 存活探针，进程能处理请求即返回 200，不检查依赖，避免数据库故障时被编排系统反复重启
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz 就绪探针，返回各组件的状态；任一组件失败或正在优雅关闭时返回 503。
+// API 端口上对外提供，不包含错误信息和组件详情
+func Readyz(c *gin.Context) {
+	report := Readiness.Report(c.Request.Context())
+	c.JSON(readyStatus(report), report.Summary())
+}
+
+// ReadyzDetail 与 Readyz 相同，但包含各组件的错误信息和详情，只在管理端口提供
+func ReadyzDetail(c *gin.Context) {
+	report := Readiness.Report(c.Request.Context())
+	c.JSON(readyStatus(report), report)
+}
+
+func readyStatus(report health.Report) int {
+	if !report.Ready() {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
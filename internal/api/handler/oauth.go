@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/oauth"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const oauthStateCookie = "oauth_state"
+
+// ListOAuthProviders 返回可用的第三方登录提供方
+func ListOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": oauth.Names()})
+}
+
+// OAuthLogin 跳转到提供方的授权页面，state、nonce 和 PKCE verifier 签名后保存在 Cookie 中
+func OAuthLogin(c *gin.Context) {
+	provider, ok := oauth.Providers[c.Param("provider")]
+	if !ok {
+		middleware.Abort(c, http.StatusNotFound, CodeProviderNotFound, "Unknown provider")
+		return
+	}
+
+	stateValue, err := randomState()
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate state")
+		return
+	}
+	nonce, err := randomState()
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate state")
+		return
+	}
+	state := utils.OAuthState{
+		Provider: provider.Config.Name,
+		State:    stateValue,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		authLog.WarnContext(c.Request.Context(), "OAuth provider unavailable", "provider", provider.Config.Name, "error", err)
+		middleware.Abort(c, http.StatusBadGateway, CodeProviderUnavailable, "Identity provider unavailable")
+		return
+	}
+
+	cookie, err := utils.GenerateOAuthStateToken(state)
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate state")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, cookie, 600, "/api/oauth", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback 提供方回调：校验 state，用授权码换取身份，并关联或创建用户
+func OAuthCallback(c *gin.Context) {
+	provider, ok := oauth.Providers[c.Param("provider")]
+	if !ok {
+		middleware.Abort(c, http.StatusNotFound, CodeProviderNotFound, "Unknown provider")
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		middleware.Abort(c, http.StatusUnauthorized, CodeOAuthDenied, "Authorization denied: "+errCode)
+		return
+	}
+
+	cookie, err := c.Cookie(oauthStateCookie)
+	if err != nil {
+		middleware.Abort(c, http.StatusBadRequest, CodeInvalidOAuthState, "Missing OAuth state")
+		return
+	}
+
+	// state 只能使用一次
+	c.SetCookie(oauthStateCookie, "", -1, "/api/oauth", "", c.Request.TLS != nil, true)
+
+	state, err := utils.ValidateOAuthStateToken(cookie)
+	if err != nil || state.Provider != provider.Config.Name ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		middleware.Abort(c, http.StatusBadRequest, CodeInvalidOAuthState, "Invalid OAuth state")
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		authLog.InfoContext(c.Request.Context(), "OAuth exchange failed", "provider", provider.Config.Name, "error", err)
+		recordAuthEvent(c, model.AuthEventLoginFailed, nil, "")
+		middleware.Abort(c, http.StatusUnauthorized, CodeOAuthFailed, "Failed to authenticate with provider")
+		return
+	}
+
+	user, err := findOrCreateOAuthUser(c.Request.Context(), identity)
+	if errors.Is(err, oauth.ErrEmailNotVerified) {
+		recordAuthEvent(c, model.AuthEventLoginFailed, nil, identity.Email)
+		middleware.Abort(c, http.StatusForbidden, CodeEmailNotVerified, "A verified email address is required")
+		return
+	} else if err != nil {
+		middleware.Error(c, err, "Failed to sign in")
+		return
+	}
+
+	// 与密码登录一样写入审计记录；开启了两步验证时登录由 LoginTwoFactor 完成
+	if user.TOTPEnabled {
+		recordAuthEvent(c, model.AuthEventTwoFactorChallenge, &user.ID, user.Email)
+	} else {
+		recordAuthEvent(c, model.AuthEventLoginSucceeded, &user.ID, user.Email)
+	}
+	completeLogin(c, *user)
+}
+
+// ListIdentities 列出当前用户关联的第三方身份
+func ListIdentities(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	identities, err := db.Store.Identities().List(c.Request.Context(), userID.(uint))
+	if err != nil {
+		middleware.Error(c, err, "Failed to fetch identities")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// DeleteIdentity 解除第三方身份关联
+func DeleteIdentity(c *gin.Context) {
+	identityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		invalidID(c)
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	identities := db.Store.Identities()
+
+	identity, err := identities.Get(ctx, user.ID, uint(identityID))
+	if errors.Is(err, storage.ErrNotFound) {
+		middleware.Abort(c, http.StatusNotFound, CodeIdentityNotFound, "Identity not found")
+		return
+	} else if err != nil {
+		middleware.Error(c, err, "Failed to unlink identity")
+		return
+	}
+
+	// 通过第三方登录创建的账号没有密码，不能解除最后一个登录方式
+	if user.Password == "" {
+		linked, err := identities.List(ctx, user.ID)
+		if err != nil {
+			middleware.Error(c, err, "Failed to unlink identity")
+			return
+		}
+		if len(linked) <= 1 {
+			middleware.Abort(c, http.StatusConflict, CodeLastSignInMethod, "Cannot unlink the only sign-in method")
+			return
+		}
+	}
+
+	if err := identities.Delete(ctx, user.ID, identity.ID); err != nil {
+		middleware.Error(c, err, "Failed to unlink identity")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identity": identity})
+}
+
+// findOrCreateOAuthUser 按 (provider, subject) 查找已关联的用户；
+// 未关联时按已验证的邮箱关联到现有用户，都没有则创建新用户
+func findOrCreateOAuthUser(ctx context.Context, identity *oauth.Identity) (*model.User, error) {
+	identities := db.Store.Identities()
+	user, err := identities.GetUser(ctx, identity.Provider, identity.Subject)
+	if !errors.Is(err, storage.ErrNotFound) {
+		return user, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, oauth.ErrEmailNotVerified
+	}
+
+	user = &model.User{
+		Email: identity.Email,
+		Name:  identity.Name,
+	}
+	err = identities.Link(ctx, user, &model.Identity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// randomState 生成 state 和 nonce 使用的随机值
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/oauth"
+	"todo-backend/pkg/oauth/oauthtest"
+	"todo-backend/pkg/storage/sqlite"
+	"todo-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testProvider = "oauthtest"
+
+// oauthEnv 连接到模拟提供方的登录接口，数据库为临时的 SQLite
+type oauthEnv struct {
+	router   *gin.Engine
+	provider *oauthtest.Server
+}
+
+func newOAuthEnv(t *testing.T) *oauthEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	utils.InitJWT("oauth-test-secret-0123456789abcdef", time.Hour)
+
+	db.InitDB(sqlite.Driver, filepath.Join(t.TempDir(), "todo.db"), db.Options{})
+	t.Cleanup(func() { db.Close() })
+	sqlDB, err := db.Store.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := migrations.For(sqlite.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(sqlDB, sqlite.Driver, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	provider := oauthtest.NewServer()
+	t.Cleanup(provider.Close)
+	oauth.Register(oauth.NewProvider(provider.ProviderConfig(testProvider, "http://api.test/api/oauth/"+testProvider+"/callback")))
+	t.Cleanup(func() { delete(oauth.Providers, testProvider) })
+
+	r := gin.New()
+	r.GET("/api/oauth/:provider/login", OAuthLogin)
+	r.GET("/api/oauth/:provider/callback", OAuthCallback)
+	return &oauthEnv{router: r, provider: provider}
+}
+
+// login 请求登录接口，返回提供方的授权地址和 state Cookie
+func (e *oauthEnv) login(t *testing.T) (*url.URL, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/oauth/"+testProvider+"/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login = %d %s", w.Code, w.Body)
+	}
+
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oauthStateCookie {
+			return authURL, cookie
+		}
+	}
+	t.Fatal("login did not set the state cookie")
+	return nil, nil
+}
+
+// authorize 让模拟提供方同意授权，返回带有 code 和 state 的回调地址
+func (e *oauthEnv) authorize(t *testing.T, authURL *url.URL) *url.URL {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize = %d", resp.StatusCode)
+	}
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback
+}
+
+// callback 带着 state Cookie 请求回调接口
+func (e *oauthEnv) callback(t *testing.T, callback *url.URL, cookie *http.Cookie) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("callback returned %d %q: %v", w.Code, w.Body, err)
+	}
+	return w.Code, body
+}
+
+// signIn 完成一次登录流程并返回回调的响应
+func (e *oauthEnv) signIn(t *testing.T) (int, map[string]interface{}) {
+	t.Helper()
+	authURL, cookie := e.login(t)
+	return e.callback(t, e.authorize(t, authURL), cookie)
+}
+
+func createTestUser(t *testing.T, email string) *model.User {
+	t.Helper()
+	user := &model.User{Email: email, Password: "Password123!", Name: "Existing"}
+	if err := db.Store.Users().Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func identityCount(t *testing.T, userID uint) int {
+	t.Helper()
+	identities, err := db.Store.Identities().List(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, identity := range identities {
+		if identity.Provider == testProvider {
+			n++
+		}
+	}
+	return n
+}
+
+// authEvents 按写入顺序返回审计记录的事件类型
+func authEvents(t *testing.T) []string {
+	t.Helper()
+	sqlDB, err := db.Store.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := sqlDB.Query("SELECT event FROM auth_events ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var events []string
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func expectAuthEvents(t *testing.T, want ...string) {
+	t.Helper()
+	if got := authEvents(t); !slices.Equal(got, want) {
+		t.Fatalf("auth events = %v, want %v", got, want)
+	}
+}
+
+func TestOAuthLoginUsesPKCE(t *testing.T) {
+	e := newOAuthEnv(t)
+	authURL, cookie := e.login(t)
+
+	state, err := utils.ValidateOAuthStateToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := authURL.Query()
+	sum := sha256.Sum256([]byte(state.Verifier))
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("authorization URL does not carry the S256 challenge of the verifier: %s", authURL)
+	}
+	if q.Get("state") != state.State || q.Get("nonce") != state.Nonce {
+		t.Fatalf("authorization URL state/nonce do not match the cookie: %s", authURL)
+	}
+
+	status, body := e.callback(t, e.authorize(t, authURL), cookie)
+	if status != http.StatusOK || body["token"] == nil {
+		t.Fatalf("callback = %d %v", status, body)
+	}
+	user, err := db.Store.Users().GetByEmail(context.Background(), "oauthtest@example.com")
+	if err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if identityCount(t, user.ID) != 1 {
+		t.Fatal("identity was not linked to the new user")
+	}
+	expectAuthEvents(t, model.AuthEventLoginSucceeded)
+}
+
+func TestOAuthCallbackRejectsWrongVerifier(t *testing.T) {
+	e := newOAuthEnv(t)
+	authURL, cookie := e.login(t)
+	callback := e.authorize(t, authURL)
+
+	// state 正确但 verifier 与授权时的 challenge 不符，提供方拒绝换取 Token
+	state, err := utils.ValidateOAuthStateToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Verifier = "a-different-verifier-that-does-not-match-the-challenge"
+	forged, err := utils.GenerateOAuthStateToken(*state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := e.callback(t, callback, &http.Cookie{Name: oauthStateCookie, Value: forged})
+	if status != http.StatusUnauthorized || body["code"] != CodeOAuthFailed {
+		t.Fatalf("callback = %d %v", status, body)
+	}
+	expectAuthEvents(t, model.AuthEventLoginFailed)
+}
+
+func TestOAuthCallbackRejectsStateMismatch(t *testing.T) {
+	e := newOAuthEnv(t)
+	authURL, cookie := e.login(t)
+	callback := e.authorize(t, authURL)
+
+	tampered := *callback
+	q := tampered.Query()
+	q.Set("state", "not-the-state-in-the-cookie")
+	tampered.RawQuery = q.Encode()
+	if status, body := e.callback(t, &tampered, cookie); status != http.StatusBadRequest || body["code"] != CodeInvalidOAuthState {
+		t.Fatalf("callback with mismatched state = %d %v", status, body)
+	}
+
+	if status, body := e.callback(t, callback, nil); status != http.StatusBadRequest || body["code"] != CodeInvalidOAuthState {
+		t.Fatalf("callback without state cookie = %d %v", status, body)
+	}
+}
+
+func TestOAuthLinksExistingUserByVerifiedEmail(t *testing.T) {
+	e := newOAuthEnv(t)
+	existing := createTestUser(t, "linked@example.com")
+
+	e.provider.SetUser(oauthtest.User{Subject: "unverified", Email: "linked@example.com", EmailVerified: false})
+	if status, body := e.signIn(t); status != http.StatusForbidden || body["code"] != CodeEmailNotVerified {
+		t.Fatalf("sign in with unverified email = %d %v", status, body)
+	}
+	if identityCount(t, existing.ID) != 0 {
+		t.Fatal("unverified email was linked")
+	}
+	expectAuthEvents(t, model.AuthEventLoginFailed)
+
+	e.provider.SetUser(oauthtest.User{Subject: "verified", Email: "linked@example.com", EmailVerified: true})
+	status, body := e.signIn(t)
+	if status != http.StatusOK || body["token"] == nil {
+		t.Fatalf("sign in with verified email = %d %v", status, body)
+	}
+	if user, _ := body["user"].(map[string]interface{}); user["id"] != float64(existing.ID) {
+		t.Fatalf("signed in as %v, want the existing user %d", body["user"], existing.ID)
+	}
+	if identityCount(t, existing.ID) != 1 {
+		t.Fatal("identity was not linked to the existing user")
+	}
+
+	// 再次登录按已关联的身份找到同一用户
+	if status, body := e.signIn(t); status != http.StatusOK || body["user"].(map[string]interface{})["id"] != float64(existing.ID) {
+		t.Fatalf("second sign in = %d %v", status, body)
+	}
+}
+
+func TestOAuthTwoFactorChallenge(t *testing.T) {
+	e := newOAuthEnv(t)
+	existing := createTestUser(t, "oauthtest@example.com")
+	ctx := context.Background()
+	if err := db.Store.Users().SetTOTPSecret(ctx, existing.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Store.Users().EnableTwoFactor(ctx, existing.ID, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := e.signIn(t)
+	if status != http.StatusOK || body["two_factor_required"] != true || body["token"] != nil {
+		t.Fatalf("callback = %d %v", status, body)
+	}
+	challenge, _ := body["challenge_token"].(string)
+	if userID, err := utils.ValidateChallengeToken(challenge); err != nil || userID != existing.ID {
+		t.Fatalf("challenge token = %d, %v", userID, err)
+	}
+	expectAuthEvents(t, model.AuthEventTwoFactorChallenge)
+}
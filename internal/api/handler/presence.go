@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/service"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceUser 在线状态快照中的一项
+type PresenceUser struct {
+	ws.PresenceEntry
+	Name string `json:"name"`
+}
+
+// GetPresence 返回主题（如 list:1、todo:5）当前的在线用户及其 viewing/editing 状态
+func GetPresence(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	topic := c.Param("topic")
+
+	kind, id, err := ws.ParseTopic(topic)
+	if err != nil || kind == ws.TopicUser {
+		middleware.Abort(c, http.StatusBadRequest, ws.ErrCodeInvalidTopic, "Invalid topic")
+		return
+	}
+
+	if err := service.AuthorizeTopic(c.Request.Context(), userID.(uint), kind, id); err != nil {
+		topicError(c, err, "Failed to fetch presence")
+		return
+	}
+
+	entries := ws.Manager.Presence(topic)
+
+	ids := make([]uint, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.UserID
+	}
+
+	users, err := db.Store.Users().ListByIDs(c.Request.Context(), ids)
+	if err != nil {
+		middleware.Error(c, err, "Failed to fetch presence")
+		return
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+
+	result := make([]PresenceUser, len(entries))
+	for i, entry := range entries {
+		result[i] = PresenceUser{PresenceEntry: entry, Name: names[entry.UserID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"topic": topic,
+		"users": result,
+	})
+}
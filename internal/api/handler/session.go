@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/utils"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionResponse 会话列表中的一项
+type SessionResponse struct {
+	model.Session
+	Current     bool `json:"current"`     // 是否为发起请求的会话
+	Connections int  `json:"connections"` // 当前的 WebSocket 连接数
+}
+
+// createSession 为用户创建登录会话并签发访问 Token
+func createSession(c *gin.Context, userID uint) (string, error) {
+	now := time.Now()
+	session := model.Session{
+		UserID:     userID,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.AccessTokenTTL),
+	}
+
+	if err := db.Store.Sessions().Create(c.Request.Context(), &session); err != nil {
+		return "", err
+	}
+
+	return utils.GenerateToken(userID, session.ID)
+}
+
+// ListSessions 列出当前用户的有效会话
+func ListSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	currentID := c.GetUint("sessionID")
+
+	sessions, err := db.Store.Sessions().ListActive(c.Request.Context(), userID, time.Now())
+	if err != nil {
+		middleware.Error(c, err, "Failed to fetch sessions")
+		return
+	}
+
+	result := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		result[i] = SessionResponse{
+			Session:     session,
+			Current:     session.ID == currentID,
+			Connections: ws.Manager.SessionConnections(session.UserID, session.ID),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSession 撤销指定会话，并立即断开该会话的 WebSocket 连接
+func RevokeSession(c *gin.Context) {
+	userID := c.GetUint("userID")
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		invalidID(c)
+		return
+	}
+
+	session, err := db.Store.Sessions().Revoke(c.Request.Context(), userID, uint(sessionID), time.Now())
+	if errors.Is(err, storage.ErrNotFound) {
+		middleware.Abort(c, http.StatusNotFound, CodeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		middleware.Error(c, err, "Failed to revoke session")
+		return
+	}
+
+	ws.Manager.CloseSession(session.UserID, session.ID, ws.CloseSessionRevoked, "session revoked")
+
+	c.JSON(http.StatusOK, gin.H{"session": session})
+}
+
+// RevokeOtherSessions 退出除当前会话以外的所有设备
+func RevokeOtherSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	currentID := c.GetUint("sessionID")
+
+	sessions, err := db.Store.Sessions().RevokeOthers(c.Request.Context(), userID, currentID, time.Now())
+	if err != nil {
+		middleware.Error(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	for _, session := range sessions {
+		ws.Manager.CloseSession(session.UserID, session.ID, ws.CloseSessionRevoked, "session revoked")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": len(sessions)})
+}
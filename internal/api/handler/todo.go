@@ -1,23 +1,18 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
 
+	"todo-backend/internal/api/middleware"
 	"todo-backend/internal/model"
-	"todo-backend/pkg/db"
-	"todo-backend/pkg/ws"
+	"todo-backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 // 统一的响应结构
-type Response struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
-}
+type Response = service.Event
 
 // CreateTodo 创建待办事项
 func CreateTodo(c *gin.Context) {
@@ -25,78 +20,21 @@ func CreateTodo(c *gin.Context) {
 
 	var input model.TodoCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.BindError(c, err)
 		return
 	}
 
-	// 检查是否存在 localID
-	var todo model.Todo
-	if input.LocalID != "" {
-		// 如果存在 localID，尝试查找并更新任务
-		if err := db.DB.Where("local_id = ? AND user_id = ?", input.LocalID, userID).First(&todo).Error; err == nil {
-			// 更新任务
-			todo.Title = input.Title
-			todo.Description = input.Description
-			todo.DueDate = input.DueDate
-			todo.RepeatType = input.RepeatType
-			todo.Note = input.Note
-			todo.IsCompleted = false
-			todo.IsFavorite = false
-
-			if err := db.DB.Save(&todo).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
-				return
-			}
-
-			response := Response{
-				Type: "todo_updated",
-				Data: todo,
-			}
-
-			// 发送 WebSocket 通知
-			notification, _ := json.Marshal(response)
-			if clients, ok := ws.Manager.Clients[userID.(uint)]; ok {
-				for client := range clients {
-					client.Send <- notification
-				}
-			}
-
-			c.JSON(http.StatusOK, response)
-			return
-		}
-	}
-
-	// 如果不存在 localID 或未找到任务，则创建新任务
-	todo = model.Todo{
-		UserID:      userID.(uint),
-		Title:       input.Title,
-		Description: input.Description,
-		DueDate:     input.DueDate,
-		RepeatType:  input.RepeatType,
-		Note:        input.Note,
-		IsCompleted: false,
-		IsFavorite:  false,
-		LocalID:     input.LocalID, // 保存 localID
-	}
-
-	if err := db.DB.Create(&todo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
+	response, err := service.CreateTodo(c.Request.Context(), userID.(uint), input)
+	if err != nil {
+		middleware.Error(c, err, "Failed to create todo")
 		return
 	}
 
-	response := Response{
-		Type: "todo_created",
-		Data: todo,
-	}
-
-	// 发送 WebSocket 通知
-	notification, _ := json.Marshal(response)
-	if clients, ok := ws.Manager.Clients[userID.(uint)]; ok {
-		for client := range clients {
-			client.Send <- notification
-		}
+	// 通过 localID 命中已有任务时按更新处理
+	if response.Type == service.EventTodoUpdated {
+		c.JSON(http.StatusOK, response)
+		return
 	}
-
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -105,47 +43,22 @@ func UpdateTodo(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	todoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
-		return
-	}
-
-	var todo model.Todo
-	if err := db.DB.Where("id = ? AND user_id = ?", todoID, userID).First(&todo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+		invalidID(c)
 		return
 	}
 
 	var input model.TodoCreate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.BindError(c, err)
 		return
 	}
 
-	todo.Title = input.Title
-	todo.Description = input.Description
-	todo.DueDate = input.DueDate
-	todo.RepeatType = input.RepeatType
-	todo.Note = input.Note
-	todo.LocalID = input.LocalID // 更新 localID
-
-	if err := db.DB.Save(&todo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+	response, err := service.UpdateTodo(c.Request.Context(), userID.(uint), uint(todoID), input)
+	if err != nil {
+		middleware.Error(c, err, "Failed to update todo")
 		return
 	}
 
-	response := Response{
-		Type: "todo_updated",
-		Data: todo,
-	}
-
-	// 发送 WebSocket 通知
-	notification, _ := json.Marshal(response)
-	if clients, ok := ws.Manager.Clients[userID.(uint)]; ok {
-		for client := range clients {
-			client.Send <- notification
-		}
-	}
-
 	c.JSON(http.StatusOK, response)
 }
 
@@ -154,34 +67,15 @@ func DeleteTodo(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	todoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		log.Println("DeleteTodo Invalid todo ID: %ld", todoID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
-		return
-	}
-
-	var todo model.Todo
-	if err := db.DB.Where("id = ? AND user_id = ?", todoID, userID).First(&todo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
-		return
-	}
-
-	if err := db.DB.Delete(&todo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete todo"})
+		invalidID(c)
 		return
 	}
 
 	// 统一返回完整的todo对象
-	response := Response{
-		Type: "todo_deleted",
-		Data: todo,
-	}
-
-	// 发送 WebSocket 通知
-	notification, _ := json.Marshal(response)
-	if clients, ok := ws.Manager.Clients[userID.(uint)]; ok {
-		for client := range clients {
-			client.Send <- notification
-		}
+	response, err := service.DeleteTodo(c.Request.Context(), userID.(uint), uint(todoID))
+	if err != nil {
+		middleware.Error(c, err, "Failed to delete todo")
+		return
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -192,37 +86,16 @@ func ToggleTodo(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	todoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
-		return
-	}
-
-	var todo model.Todo
-	if err := db.DB.Where("id = ? AND user_id = ?", todoID, userID).First(&todo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+		invalidID(c)
 		return
 	}
 
-	todo.IsCompleted = !todo.IsCompleted
-
-	if err := db.DB.Save(&todo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+	response, err := service.ToggleTodo(c.Request.Context(), userID.(uint), uint(todoID))
+	if err != nil {
+		middleware.Error(c, err, "Failed to update todo")
 		return
 	}
 
-	// 使用 todo_updated 类型统一更新操作
-	response := Response{
-		Type: "todo_updated",
-		Data: todo,
-	}
-
-	// 发送 WebSocket 通知
-	notification, _ := json.Marshal(response)
-	if clients, ok := ws.Manager.Clients[userID.(uint)]; ok {
-		for client := range clients {
-			client.Send <- notification
-		}
-	}
-
 	c.JSON(http.StatusOK, response)
 }
 
@@ -230,16 +103,11 @@ func ToggleTodo(c *gin.Context) {
 func GetTodos(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
-	var todos []model.Todo
-	if err := db.DB.Where("user_id = ?", userID).Find(&todos).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todos"})
+	response, err := service.ListTodos(c.Request.Context(), userID.(uint))
+	if err != nil {
+		middleware.Error(c, err, "Failed to fetch todos")
 		return
 	}
 
-	response := Response{
-		Type: "todos_list",
-		Data: todos,
-	}
-
 	c.JSON(http.StatusOK, response)
 }
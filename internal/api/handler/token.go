@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/utils"
+	"todo-backend/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永不过期
+}
+
+// CreateToken 创建 Personal Access Token，明文只在创建时返回一次
+func CreateToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	var fields []middleware.FieldError
+	for i, scope := range req.Scopes {
+		if !validScope(scope) {
+			fields = append(fields, middleware.FieldError{
+				Field:   fmt.Sprintf("scopes[%d]", i),
+				Code:    "oneof",
+				Message: "must be one of: " + strings.Join(model.Scopes, " "),
+			})
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		fields = append(fields, middleware.FieldError{Field: "expires_at", Code: "future", Message: "must be in the future"})
+	}
+	if len(fields) > 0 {
+		middleware.ValidationFailed(c, fields...)
+		return
+	}
+
+	plaintext, hash, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate token")
+		return
+	}
+
+	token := model.PersonalAccessToken{
+		UserID:    userID.(uint),
+		Name:      req.Name,
+		TokenHash: hash,
+		Prefix:    plaintext[:len(utils.PersonalAccessTokenPrefix)+6],
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := db.Store.Tokens().Create(c.Request.Context(), &token); err != nil {
+		middleware.Error(c, err, "Failed to create token")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":                 plaintext,
+		"personal_access_token": token,
+	})
+}
+
+// ListTokens 列出当前用户未撤销的 Personal Access Token
+func ListTokens(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	tokens, err := db.Store.Tokens().List(c.Request.Context(), userID.(uint))
+	if err != nil {
+		middleware.Error(c, err, "Failed to fetch tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"personal_access_tokens": tokens})
+}
+
+// RevokeToken 撤销 Personal Access Token，立即失效
+func RevokeToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	tokenID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		invalidID(c)
+		return
+	}
+
+	token, err := db.Store.Tokens().Revoke(c.Request.Context(), userID.(uint), uint(tokenID), time.Now())
+	if errors.Is(err, storage.ErrNotFound) {
+		middleware.Abort(c, http.StatusNotFound, CodeTokenNotFound, "Token not found")
+		return
+	} else if err != nil {
+		middleware.Error(c, err, "Failed to revoke token")
+		return
+	}
+
+	ws.Manager.CloseToken(token.UserID, token.ID, ws.CloseSessionRevoked, "token revoked")
+
+	c.JSON(http.StatusOK, gin.H{"personal_access_token": token})
+}
+
+func validScope(scope string) bool {
+	for _, s := range model.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TOTPIssuer 验证器 App 中显示的服务名称，启动时由配置设置
+var TOTPIssuer = "Todo App"
+
+// SetupTwoFactor 开始开启两步验证：生成密钥并返回二维码链接，需调用 ConfirmTwoFactor 确认后生效
+func SetupTwoFactor(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		return
+	}
+
+	if user.TOTPEnabled {
+		middleware.Abort(c, http.StatusConflict, CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate secret")
+		return
+	}
+
+	if err := db.Store.Users().SetTOTPSecret(c.Request.Context(), user.ID, secret); err != nil {
+		middleware.Error(c, err, "Failed to save secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": utils.TOTPProvisioningURI(secret, TOTPIssuer, user.Email),
+	})
+}
+
+// ConfirmTwoFactor 用验证器生成的验证码确认开启两步验证，并返回恢复码（仅此一次）
+func ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		return
+	}
+
+	if user.TOTPEnabled {
+		middleware.Abort(c, http.StatusConflict, CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		middleware.Abort(c, http.StatusBadRequest, CodeTwoFactorNotStarted, "Two-factor setup has not been started")
+		return
+	}
+
+	if loginLocked(c, user.Email) {
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		secondFactorFailed(c, user)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		ok, err = db.Store.Users().EnableTwoFactor(c.Request.Context(), user.ID, step, hashes)
+	}
+	if err != nil {
+		middleware.Error(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+	if !ok {
+		// 同一验证码的并发请求已经开启了两步验证
+		secondFactorFailed(c, user)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor 关闭两步验证，需要同时提供密码和验证码（或恢复码）
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		middleware.Abort(c, http.StatusBadRequest, CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if loginLocked(c, user.Email) {
+		return
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		loginFailed(c, model.AuthEventLoginFailed, &user.ID, user.Email)
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidPassword, "Invalid password")
+		return
+	}
+
+	if ok, err := verifySecondFactor(c.Request.Context(), user, req.Code); err != nil {
+		middleware.Error(c, err, "Failed to verify code")
+		return
+	} else if !ok {
+		secondFactorFailed(c, user)
+		return
+	}
+
+	if err := db.Store.Users().DisableTwoFactor(c.Request.Context(), user.ID); err != nil {
+		middleware.Error(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"two_factor_enabled": false})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		middleware.Abort(c, http.StatusBadRequest, CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if loginLocked(c, user.Email) {
+		return
+	}
+
+	// 只接受 TOTP 验证码，避免用恢复码无限换取新的恢复码
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		secondFactorFailed(c, user)
+		return
+	}
+
+	// 时间步在替换恢复码的同一事务中占用，已使用过的验证码不能再换取恢复码
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		ok, err = db.Store.Users().ReplaceRecoveryCodes(c.Request.Context(), user.ID, step, hashes)
+	}
+	if err != nil {
+		middleware.Error(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+	if !ok {
+		secondFactorFailed(c, user)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// LoginTwoFactor 登录第二步：用挑战 Token 和验证码换取正式 Token
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	userID, err := utils.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidChallenge, "Invalid or expired challenge token")
+		return
+	}
+
+	user, err := db.Store.Users().Get(c.Request.Context(), userID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		middleware.Error(c, err, "Failed to verify code")
+		return
+	}
+	if err != nil || !user.TOTPEnabled {
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidChallenge, "Invalid or expired challenge token")
+		return
+	}
+
+	// 验证码同样计入账号的失败次数，防止在挑战 Token 有效期内穷举
+	if loginLocked(c, user.Email) {
+		return
+	}
+
+	if ok, err := verifySecondFactor(c.Request.Context(), user, req.Code); err != nil {
+		middleware.Error(c, err, "Failed to verify code")
+		return
+	} else if !ok {
+		loginFailed(c, model.AuthEventTwoFactorFailed, &user.ID, user.Email)
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidCode, "Invalid verification code")
+		return
+	}
+
+	loginSucceeded(c, model.AuthEventTwoFactorSucceeded, user)
+
+	token, err := createSession(c, user.ID)
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
+
+// currentUser 读取当前登录的用户，失败时写入错误响应并返回 nil
+func currentUser(c *gin.Context) *model.User {
+	user, err := db.Store.Users().Get(c.Request.Context(), c.GetUint("userID"))
+	if errors.Is(err, storage.ErrNotFound) {
+		middleware.Abort(c, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return nil
+	} else if err != nil {
+		middleware.Error(c, err, "Failed to fetch user")
+		return nil
+	}
+	return user
+}
+
+// secondFactorFailed 验证码错误时计入账号的失败次数并返回 401。与登录共用同一计数，
+// 持有会话的人同样不能在确认、关闭两步验证或重新生成恢复码时穷举验证码
+func secondFactorFailed(c *gin.Context, user *model.User) {
+	loginFailed(c, model.AuthEventTwoFactorFailed, &user.ID, user.Email)
+	middleware.Abort(c, http.StatusUnauthorized, CodeInvalidCode, "Invalid verification code")
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码，成功后记录时间步或作废恢复码
+func verifySecondFactor(ctx context.Context, user *model.User, code string) (bool, error) {
+	now := time.Now()
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, now); ok {
+		// 只接受比上次更新的时间步，同一验证码不能使用两次
+		used, err := db.Store.Users().UseTOTPStep(ctx, user.ID, step)
+		if err != nil || !used {
+			return false, err
+		}
+		user.TOTPLastStep = step
+		return true, nil
+	}
+
+	return db.Store.Users().UseRecoveryCode(ctx, user.ID, utils.HashRecoveryCode(code), now)
+}
+
+// generateRecoveryCodes 生成一组恢复码，返回明文（只展示给用户一次）和要保存的哈希
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
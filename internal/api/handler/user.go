@@ -1,81 +1,123 @@
 package handler
 
 import (
-    "net/http"
-    
-    "github.com/gin-gonic/gin"
-    "todo-backend/internal/model"
-    "todo-backend/pkg/db"
-    "todo-backend/pkg/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/utils"
 )
 
 type RegisterRequest struct {
-    Email    string `json:"email" binding:"required,email"`
-    Password string `json:"password" binding:"required,min=6"`
-    Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Name     string `json:"name" binding:"required"`
 }
 
 type LoginRequest struct {
-    Email    string `json:"email" binding:"required,email"`
-    Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
 }
 
 func Register(c *gin.Context) {
-    var req RegisterRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    user := model.User{
-        Email:    req.Email,
-        Password: req.Password,
-        Name:     req.Name,
-    }
-
-    if err := db.DB.Create(&user).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
-        return
-    }
-
-    token, err := utils.GenerateToken(user.ID)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-        return
-    }
-
-    c.JSON(http.StatusOK, gin.H{
-        "token": token,
-        "user":  user,
-    })
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	user := model.User{
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+	}
+
+	err := db.Store.Users().Create(c.Request.Context(), &user)
+	if errors.Is(err, storage.ErrDuplicate) {
+		middleware.Abort(c, http.StatusConflict, CodeEmailTaken, "Email is already registered")
+		return
+	} else if err != nil {
+		middleware.Error(c, err, "Failed to register user")
+		return
+	}
+
+	token, err := createSession(c, user.ID)
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
 }
 
 func Login(c *gin.Context) {
-    var req LoginRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    var user model.User
-    if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-        return
-    }
-
-    if err := user.CheckPassword(req.Password); err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-        return
-    }
-
-    token, err := utils.GenerateToken(user.ID)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-        return
-    }
-
-    c.JSON(http.StatusOK, gin.H{
-        "token": token,
-        "user":  user,
-    })
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.BindError(c, err)
+		return
+	}
+
+	if loginLocked(c, req.Email) {
+		return
+	}
+
+	user, err := db.Store.Users().GetByEmail(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		middleware.Error(c, err, "Failed to sign in")
+		return
+	}
+	if err != nil {
+		model.CheckDummyPassword(req.Password)
+		loginFailed(c, model.AuthEventLoginFailed, nil, req.Email)
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		loginFailed(c, model.AuthEventLoginFailed, &user.ID, user.Email)
+		middleware.Abort(c, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	if user.TOTPEnabled {
+		recordAuthEvent(c, model.AuthEventTwoFactorChallenge, &user.ID, user.Email)
+	} else {
+		loginSucceeded(c, model.AuthEventLoginSucceeded, user)
+	}
+	completeLogin(c, *user)
+}
+
+// completeLogin 第一因素验证通过后签发 Token；开启了两步验证时只返回挑战 Token，由 LoginTwoFactor 完成登录
+func completeLogin(c *gin.Context, user model.User) {
+	if user.TOTPEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID)
+		if err != nil {
+			middleware.Error(c, err, "Failed to generate token")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	token, err := createSession(c, user.ID)
+	if err != nil {
+		middleware.Error(c, err, "Failed to generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
 }
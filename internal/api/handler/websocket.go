@@ -1,49 +1,52 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"time"
 
+	"todo-backend/internal/api/middleware"
+	"todo-backend/internal/model"
+	"todo-backend/internal/service"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/utils"
 	"todo-backend/pkg/ws"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
 )
 
+var wsLog = logging.For(logging.WS)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	// 通过 Sec-WebSocket-Protocol 协商编码，客户端支持时启用 permessage-deflate
+	Subprotocols:      ws.Subprotocols(),
+	EnableCompression: true,
 }
 
-// Claims 结构用于存储 JWT 的声明
-type Claims struct {
-	UserID               uint `json:"user_id"` // 自定义字段：用户 ID
-	jwt.RegisteredClaims      // 标准字段：exp, iat 等
-}
-
-// 定义用于签名的密钥
-var jwtKey = []byte("todo_app_secret_key_123") // 替换为你的实际密钥
-
 func HandleWebSocket(c *gin.Context) {
 	// 升级为 WebSocket 连接
-	log.Println("Handling WebSocket connection")
+	ctx := c.Request.Context()
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("Failed to set websocket upgrade: %+v", err)
+		wsLog.WarnContext(ctx, "Failed to upgrade WebSocket connection", "error", err)
 		return
 	}
 
-	// 读取第一条消息（认证消息）
+	codec := ws.CodecFor(conn.Subprotocol())
+
+	// 读取第一条消息（认证消息），与其他消息一样使用协商的编码
 	_, message, err := conn.ReadMessage()
 	if err != nil {
-		log.Printf("Failed to read auth message: %+v", err)
+		wsLog.InfoContext(ctx, "Failed to read auth message", "error", err)
 		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Failed to read auth message"))
 		conn.Close()
 		return
@@ -51,11 +54,16 @@ func HandleWebSocket(c *gin.Context) {
 
 	// 解析认证消息
 	var authMsg struct {
-		Type  string `json:"type"`
-		Token string `json:"token"`
+		Type     string `json:"type"`
+		Token    string `json:"token"`
+		Snapshot bool   `json:"snapshot"` // 先发送全部任务再开始推送实时事件
+		Since    string `json:"since"`    // 只发送该游标之后的变更
 	}
-	if err := json.Unmarshal(message, &authMsg); err != nil {
-		log.Printf("Invalid auth message: %+v", err)
+	if message, err = codec.Decode(message); err == nil {
+		err = json.Unmarshal(message, &authMsg)
+	}
+	if err != nil {
+		wsLog.InfoContext(ctx, "Invalid auth message", "error", err)
 		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Invalid auth message"))
 		conn.Close()
 		return
@@ -63,75 +71,164 @@ func HandleWebSocket(c *gin.Context) {
 
 	// 检查消息类型
 	if authMsg.Type != "auth" {
-		log.Printf("Invalid message type: %s", authMsg.Type)
+		wsLog.InfoContext(ctx, "Invalid auth message type", "type", authMsg.Type)
 		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Invalid message type"))
 		conn.Close()
 		return
 	}
 
-	// 验证 Token
-	userID, err := validateToken(authMsg.Token)
+	// 验证 Token 及其会话
+	claims, err := utils.ParseToken(authMsg.Token)
+	if err == nil {
+		var active bool
+		if active, err = middleware.TouchSession(ctx, claims); err == nil && !active {
+			err = errors.New("session revoked or expired")
+		}
+	}
 	if err != nil {
-		log.Printf("Unauthorized: %+v", err)
+		wsLog.InfoContext(ctx, "WebSocket authentication failed", "error", err)
 		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Unauthorized"))
 		conn.Close()
 		return
 	}
+	userID := claims.UserID
+
+	if authMsg.Since != "" {
+		if _, err := model.ParseCursor(authMsg.Since); err != nil {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Invalid cursor"))
+			conn.Close()
+			return
+		}
+	}
 
 	// 认证成功
-	log.Printf("Authentication successful for userID: %d", userID)
-	conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "auth_success"}`))
+	wsLog.InfoContext(ctx, "WebSocket authenticated", "user_id", userID, "session_id", claims.SessionID)
+	if frame, err := codec.Encode([]byte(`{"type": "auth_success"}`)); err == nil {
+		conn.WriteMessage(codec.MessageType(), frame)
+	}
 
 	// 注册客户端
-	client := &ws.Client{
-		ID:     userID,
-		Socket: conn,
-		Send:   make(chan []byte, 256),
+	client := ws.NewClient(userID, claims.SessionID, conn)
+	client.ExpiresAt = claims.ExpiresAt
+	client.Codec = codec
+	// 连接在请求结束后继续存在，保留请求 ID 但不随请求取消
+	client.SetContext(context.WithoutCancel(ctx))
+
+	// 需要快照时先暂存实时事件，注册完成后再读取数据，读取期间的变更不会遗漏
+	snapshot := authMsg.Snapshot || authMsg.Since != ""
+	if snapshot {
+		client.Hold()
 	}
-	ws.Manager.Register <- client
+	ws.Manager.Connect(client, nil, "")
 
 	// 启动读写 goroutines
 	go client.WritePump()
 	go client.ReadPump()
-}
 
-// parseToken 解析 JWT Token 并返回 Claims
-func parseToken(tokenString string) (*Claims, error) {
-	// 解析 Token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 检查签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return jwtKey, nil
-	})
+	if snapshot {
+		sendSnapshot(ctx, client, authMsg.Since)
+	}
+}
 
-	// 检查解析错误
+// sendSnapshot 发送快照和 snapshot_complete，然后放行暂存的实时事件（跳过快照中已包含的变更）
+func sendSnapshot(ctx context.Context, client *ws.Client, since string) {
+	snapshot, err := service.TakeSnapshot(ctx, client.ID, since)
 	if err != nil {
+		wsLog.ErrorContext(ctx, "Failed to load snapshot", "user_id", client.ID, "error", err)
+		client.Close(websocket.CloseInternalServerErr, "Failed to load snapshot")
+		return
+	}
+	ws.Manager.Release(client, snapshot.Messages(), snapshot.Covers)
+}
+
+// reauthenticate 处理 reauth 命令：校验新 Token 属于同一用户且会话有效后，替换连接的会话和到期时间
+func reauthenticate(c *ws.Client, data json.RawMessage) (interface{}, error) {
+	var input reauthCommand
+	if err := bindCommand(data, &input); err != nil {
 		return nil, err
 	}
 
-	// 验证 Claims
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
-	} else {
-		return nil, errors.New("invalid token")
+	claims, err := utils.ParseToken(input.Token)
+	if err != nil || claims.UserID != c.ID {
+		return nil, &ws.CommandError{Code: "invalid_token", Message: "Invalid token"}
 	}
+	if active, err := middleware.TouchSession(c.Context(), claims); err != nil {
+		return nil, err
+	} else if !active {
+		return nil, &ws.CommandError{Code: "invalid_token", Message: "Invalid token"}
+	}
+
+	ws.Manager.Reauthenticate(c, claims.SessionID, claims.ExpiresAt)
+	return gin.H{"expires_at": claims.ExpiresAt}, nil
 }
 
-// validateToken 验证 Token 并返回用户 ID
-func validateToken(token string) (uint, error) {
-	// 解析 Token
-	claims, err := parseToken(token)
+// validateConnections 定期检查连接对应的账号仍然存在、会话或 Personal Access Token 仍然有效，
+// 用户、会话和 Token 各批量查询一次；数据库暂时不可用时不断开连接
+func validateConnections(ctx context.Context, creds []ws.Credentials) map[ws.Credentials]error {
+	var userIDs, sessionIDs, tokenIDs []uint
+	for _, c := range creds {
+		userIDs = append(userIDs, c.UserID)
+		switch {
+		case c.TokenID != 0:
+			tokenIDs = append(tokenIDs, c.TokenID)
+		case c.SessionID != 0:
+			sessionIDs = append(sessionIDs, c.SessionID)
+		}
+	}
+
+	users, err := db.Store.Users().ListByIDs(ctx, userIDs)
+	if err != nil {
+		wsLog.Warn("Failed to revalidate connections", "error", err)
+		return nil
+	}
+	existing := make(map[uint]bool, len(users))
+	for _, user := range users {
+		existing[user.ID] = true
+	}
+
+	sessionList, err := db.Store.Sessions().ListByIDs(ctx, sessionIDs)
 	if err != nil {
-		return 0, err
+		wsLog.Warn("Failed to revalidate connections", "error", err)
+		return nil
+	}
+	sessions := make(map[uint]model.Session, len(sessionList))
+	for _, session := range sessionList {
+		sessions[session.ID] = session
 	}
 
-	// 检查 Token 是否过期
-	if time.Now().Unix() > claims.ExpiresAt.Unix() {
-		return 0, errors.New("token expired")
+	tokenList, err := db.Store.Tokens().ListByIDs(ctx, tokenIDs)
+	if err != nil {
+		wsLog.Warn("Failed to revalidate connections", "error", err)
+		return nil
+	}
+	tokens := make(map[uint]model.PersonalAccessToken, len(tokenList))
+	for _, token := range tokenList {
+		tokens[token.ID] = token
 	}
 
-	// 返回用户 ID
-	return claims.UserID, nil
+	now := time.Now()
+	invalid := make(map[ws.Credentials]error)
+	for _, c := range creds {
+		if !existing[c.UserID] {
+			invalid[c] = errors.New("user deleted")
+			continue
+		}
+		switch {
+		case c.TokenID != 0:
+			token, ok := tokens[c.TokenID]
+			if !ok || token.UserID != c.UserID {
+				invalid[c] = errors.New("token not found")
+			} else if token.RevokedAt != nil {
+				invalid[c] = errors.New("token revoked")
+			}
+		case c.SessionID != 0:
+			session, ok := sessions[c.SessionID]
+			if !ok || session.UserID != c.UserID {
+				invalid[c] = errors.New("session not found")
+			} else if !session.Active(now) {
+				invalid[c] = errors.New("session revoked or expired")
+			}
+		}
+	}
+	return invalid
 }
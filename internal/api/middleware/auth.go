@@ -1,14 +1,29 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/storage"
 	"todo-backend/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 认证方式，保存在上下文的 authMethod 中
+const (
+	AuthMethodSession             = "session"
+	AuthMethodPersonalAccessToken = "pat"
+)
+
+// lastUsedInterval last_used_at / last_seen_at 的最小更新间隔，避免每个请求都写数据库
+const lastUsedInterval = time.Minute
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 如果是 WebSocket 请求，跳过 HTTP 认证
@@ -20,29 +35,145 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 其他请求（HTTP）需要认证
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			Abort(c, http.StatusUnauthorized, CodeUnauthorized, "Authorization header is required")
 			return
 		}
 
 		// 从 Bearer token 中提取 Token
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			Abort(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid authorization header format")
+			return
+		}
+
+		// Personal Access Token
+		if utils.IsPersonalAccessToken(parts[1]) {
+			token, err := authenticatePersonalAccessToken(c.Request.Context(), parts[1])
+			if errors.Is(err, errInvalidToken) {
+				Abort(c, http.StatusUnauthorized, CodeInvalidToken, "Invalid token")
+				return
+			}
+			if err != nil {
+				Error(c, err, "Failed to verify token")
+				return
+			}
+
+			c.Set("userID", token.UserID)
+			c.Set("authMethod", AuthMethodPersonalAccessToken)
+			c.Set("scopes", token.Scopes)
+			c.Set("tokenID", token.ID)
+			if token.ExpiresAt != nil {
+				c.Set("tokenExpiresAt", *token.ExpiresAt)
+			}
+			c.Next()
 			return
 		}
 
 		// 验证 Token
-		userID, err := utils.ValidateToken(parts[1])
+		claims, err := utils.ParseToken(parts[1])
+		if err != nil {
+			Abort(c, http.StatusUnauthorized, CodeInvalidToken, "Invalid token")
+			return
+		}
+
+		// 会话被撤销后 Token 立即失效
+		active, err := TouchSession(c.Request.Context(), claims)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			Error(c, err, "Failed to verify session")
+			return
+		}
+		if !active {
+			Abort(c, http.StatusUnauthorized, CodeSessionRevoked, "Session has been revoked or expired")
 			return
 		}
 
 		// 将用户 ID 存储到上下文中
-		c.Set("userID", userID)
+		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenExpiresAt", claims.ExpiresAt)
+		c.Set("authMethod", AuthMethodSession)
+		c.Next()
+	}
+}
+
+// QueryToken 允许通过 access_token 查询参数传递 Token，仅用于浏览器 EventSource 这类无法设置请求头的场景，
+// 需放在 AuthMiddleware 之前
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// RequireScope 要求 Personal Access Token 拥有指定权限；登录会话拥有全部权限
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get("scopes"); ok {
+			token := model.PersonalAccessToken{Scopes: scopes.([]string)}
+			if !token.HasScope(scope) {
+				Abort(c, http.StatusForbidden, CodeInsufficientScope, "Token is missing required scope: "+scope)
+				return
+			}
+		}
 		c.Next()
 	}
 }
+
+// RequireSession 要求使用登录会话访问，用于账号安全相关的接口（Personal Access Token 不可访问）
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodSession {
+			Abort(c, http.StatusForbidden, CodeSessionRequired, "This endpoint requires a login session")
+			return
+		}
+		c.Next()
+	}
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// authenticatePersonalAccessToken 校验 Token 未撤销、未过期，并记录最近使用时间。
+// Token 无效时返回 errInvalidToken，其他错误来自数据库
+func authenticatePersonalAccessToken(ctx context.Context, plaintext string) (*model.PersonalAccessToken, error) {
+	token, err := db.Store.Tokens().GetByHash(ctx, utils.HashPersonalAccessToken(plaintext))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, errInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedInterval {
+		db.Store.Tokens().Touch(ctx, token.ID, now)
+	}
+
+	return token, nil
+}
+
+// TouchSession 校验 Token 对应的会话仍然有效，并记录最近活跃时间；error 只在读取会话失败时返回
+func TouchSession(ctx context.Context, claims *utils.Claims) (bool, error) {
+	session, err := db.Store.Sessions().Get(ctx, claims.SessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if session.UserID != claims.UserID || !session.Active(now) {
+		return false, nil
+	}
+
+	if now.Sub(session.LastSeenAt) > lastUsedInterval {
+		db.Store.Sessions().Touch(ctx, session.ID, now)
+	}
+	return true, nil
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"time"
+
+	"todo-backend/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest 客户端在响应之前断开连接（nginx 的约定），只用于日志
+const StatusClientClosedRequest = 499
+
+// StatementTimeouts 按路由覆盖数据库语句的超时时间，键为 "METHOD /path"（路径与路由定义一致）。
+// 未覆盖的路由使用 db.Options.StatementTimeout
+func StatementTimeouts(routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout, ok := routes[c.Request.Method+" "+c.FullPath()]; ok {
+			c.Request = c.Request.WithContext(storage.WithStatementTimeout(c.Request.Context(), timeout))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"todo-backend/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 所在的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID 接受上游（负载均衡、网关）传入的请求 ID 的格式，不符合时重新生成
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+var accessLog = logging.For(logging.HTTP)
+
+// RequestID 为每个请求分配 ID，写入响应头和请求的 ctx，之后的日志（包括 SQL 和 WebSocket 连接）都带有该 ID
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Set("requestID", id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog 请求结束后记录访问日志。只记录路径不记录查询参数（SSE 的 access_token 在查询参数中）
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"ip", c.ClientIP(),
+		}
+		if userID := c.GetUint("userID"); userID != 0 {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+		accessLog.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery 处理函数 panic 时记录错误和调用栈，返回 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		accessLog.ErrorContext(c.Request.Context(), "panic recovered", "panic", err, "stack", string(debug.Stack()))
+		Abort(c, http.StatusInternalServerError, CodeInternal, "Internal server error")
+	})
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todo-backend/internal/api/openapi"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateOpenAPI 按 OpenAPI 文档校验请求和响应，用于开发和测试环境。
+// 请求不符合文档时返回 400 和逐个字段的错误；响应已经发出，不符合时只记录错误日志，用来发现文档和实现的出入。
+// 文档中没有的路由不校验（启动时由 openapi.CheckRoutes 报告）
+func ValidateOpenAPI() gin.HandlerFunc {
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+
+	return func(c *gin.Context) {
+		route, params, err := openapi.Router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(ctx, input); malformed(err) {
+			Abort(c, http.StatusBadRequest, CodeMalformedRequest, "Request body is not valid JSON")
+			return
+		} else if err != nil {
+			p := problem(c, http.StatusBadRequest, CodeValidationFailed, "Request does not match the API specification")
+			p.Errors = specErrors(err)
+			abort(c, p)
+			return
+		}
+
+		// WebSocket 和 SSE 的响应是持续的流，不缓存也不校验
+		if streams(route) {
+			c.Next()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 w.Status(),
+			Header:                 w.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(w.body.Bytes())),
+			Options:                options,
+		})
+		if err != nil {
+			accessLog.ErrorContext(ctx, "Response does not match the API specification",
+				"route", c.FullPath(), "method", c.Request.Method, "status", w.Status(), "error", err)
+		}
+	}
+}
+
+// specErrors 把请求校验的错误转成字段错误：请求体按 JSON 路径，参数按参数名
+func specErrors(err error) []FieldError {
+	var fields []FieldError
+	for _, err := range flatten(err) {
+		field := FieldError{Field: "body", Code: "schema", Message: err.Error()}
+		var reqErr *openapi3filter.RequestError
+		if errors.As(err, &reqErr) {
+			if reqErr.Parameter != nil {
+				field.Field = reqErr.Parameter.Name
+			}
+			if reqErr.Reason != "" {
+				field.Message = reqErr.Reason
+			}
+		}
+		var schemaErr *openapi3.SchemaError
+		if errors.As(err, &schemaErr) {
+			if path := jsonPath(schemaErr.JSONPointer()); path != "" {
+				field.Field = path
+			}
+			field.Code = schemaErr.SchemaField
+			field.Message = schemaErr.Reason
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// malformed 请求体无法解析为 JSON
+func malformed(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, err := range flatten(err) {
+		var (
+			reqErr   *openapi3filter.RequestError
+			parseErr *openapi3filter.ParseError
+		)
+		if errors.As(err, &reqErr) && reqErr.RequestBody != nil && errors.As(err, &parseErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten 展开 MultiError：MultiError 模式下请求体的多个字段错误包在同一个 RequestError 中
+func flatten(err error) []error {
+	var multi openapi3.MultiError
+	if !errors.As(err, &multi) {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range multi {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// jsonPath 把 JSON Pointer 的各段拼成与 BindError 一致的写法，例如 scopes[0]
+func jsonPath(pointer []string) string {
+	var b strings.Builder
+	for _, segment := range pointer {
+		if _, err := strconv.Atoi(segment); err == nil {
+			b.WriteString("[" + segment + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+// streams 接口是否返回 101 或 text/event-stream
+func streams(route *routers.Route) bool {
+	for code, response := range route.Operation.Responses.Map() {
+		if code == "101" {
+			return true
+		}
+		if response.Value != nil && response.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingWriter 在写出响应的同时保留一份响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"todo-backend/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// ProblemContentType RFC 7807 错误响应的 Content-Type
+const ProblemContentType = "application/problem+json"
+
+// 通用错误码；各接口特有的错误码（如 todo_not_found、email_taken）定义在 handler 中
+const (
+	CodeMalformedRequest  = "malformed_request"
+	CodeValidationFailed  = "validation_failed"
+	CodeUnauthorized      = "unauthorized"
+	CodeInvalidToken      = "invalid_token"
+	CodeSessionRevoked    = "session_revoked"
+	CodeInsufficientScope = "insufficient_scope"
+	CodeSessionRequired   = "session_required"
+	CodeNotFound          = "not_found"
+	CodeConflict          = "conflict"
+	CodeTimeout           = "timeout"
+	CodeUnavailable       = "service_unavailable"
+	CodeInternal          = "internal_error"
+)
+
+// retryAfterSeconds 数据库不可用时建议客户端的重试间隔
+const retryAfterSeconds = "5"
+
+// Problem RFC 7807 错误响应。type 固定为 about:blank，title 为状态码的标准描述，
+// 客户端按 code 区分错误，detail 只用于展示
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+}
+
+// FieldError 单个字段的校验错误，Field 为 JSON 字段路径（如 scopes[0]），Code 为校验规则（如 required、email、min）
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorMapping 按 errors.Is 匹配的错误对应的响应
+type errorMapping struct {
+	target error
+	status int
+	code   string
+	detail string
+}
+
+// mappings 由 MapError 注册，先于 defaultMappings 匹配
+var mappings []errorMapping
+
+// defaultMappings 存储层和 GORM 的错误
+var defaultMappings = []errorMapping{
+	{storage.ErrNotFound, http.StatusNotFound, CodeNotFound, "Resource not found"},
+	{gorm.ErrRecordNotFound, http.StatusNotFound, CodeNotFound, "Resource not found"},
+	{storage.ErrDuplicate, http.StatusConflict, CodeConflict, "Resource already exists"},
+	{gorm.ErrDuplicatedKey, http.StatusConflict, CodeConflict, "Resource already exists"},
+	{gorm.ErrForeignKeyViolated, http.StatusConflict, CodeConflict, "Resource is referenced by other records"},
+}
+
+// MapError 让 Error 把 target（及包装了它的错误）响应为 status 和 code，只应在 init 中调用
+func MapError(target error, status int, code, detail string) {
+	mappings = append(mappings, errorMapping{target, status, code, detail})
+}
+
+// Error 响应处理请求时遇到的错误：数据库超时返回 504，数据库不可用返回 503，客户端已断开时不再写响应体，
+// MapError 注册的错误和存储层错误（记录不存在、唯一约束冲突）按映射响应，其他错误返回 500 和 message
+func Error(c *gin.Context, err error, message string) {
+	// 错误记录在访问日志中
+	c.Error(err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		Abort(c, http.StatusGatewayTimeout, CodeTimeout, "Request timed out")
+		return
+	case errors.Is(err, storage.ErrUnavailable):
+		c.Header("Retry-After", retryAfterSeconds)
+		Abort(c, http.StatusServiceUnavailable, CodeUnavailable, "Service temporarily unavailable")
+		return
+	case errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil:
+		c.AbortWithStatus(StatusClientClosedRequest)
+		return
+	}
+
+	for _, list := range [][]errorMapping{mappings, defaultMappings} {
+		for _, m := range list {
+			if errors.Is(err, m.target) {
+				Abort(c, m.status, m.code, m.detail)
+				return
+			}
+		}
+	}
+	Abort(c, http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Abort 以 application/problem+json 响应错误并中止后续处理
+func Abort(c *gin.Context, status int, code, detail string) {
+	abort(c, problem(c, status, code, detail))
+}
+
+// BindError 响应 ShouldBindJSON 的错误：请求体无法解析时返回 malformed_request，
+// 字段类型不符或不满足 binding 规则时返回 validation_failed 和逐个字段的错误
+func BindError(c *gin.Context, err error) {
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			fields[i] = fieldError(fe)
+		}
+		ValidationFailed(c, fields...)
+	case errors.As(err, &typeErr):
+		ValidationFailed(c, FieldError{Field: typeErr.Field, Code: "type", Message: "must be of type " + typeErr.Type.String()})
+	case errors.Is(err, io.EOF):
+		Abort(c, http.StatusBadRequest, CodeMalformedRequest, "Request body is required")
+	default:
+		// 解析错误的原文可能包含请求体片段，只写入日志
+		accessLog.InfoContext(c.Request.Context(), "Request body is not valid JSON", "error", err)
+		Abort(c, http.StatusBadRequest, CodeMalformedRequest, "Request body is not valid JSON")
+	}
+}
+
+// ValidationFailed 响应 binding 规则之外的字段校验错误
+func ValidationFailed(c *gin.Context, errs ...FieldError) {
+	p := problem(c, http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+	p.Errors = errs
+	abort(c, p)
+}
+
+func problem(c *gin.Context, status int, code, detail string) *Problem {
+	p := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: c.GetString("requestID"),
+	}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+		p.TraceID = sc.TraceID().String()
+	}
+	return p
+}
+
+func abort(c *gin.Context, p *Problem) {
+	// render.JSON 只在未设置 Content-Type 时写入 application/json
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// fieldError 把 binding 规则转成面向用户的描述
+func fieldError(fe validator.FieldError) FieldError {
+	// Namespace 以结构体名开头，例如 RegisterRequest.email
+	field := fe.Namespace()
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+
+	message := "is invalid"
+	switch fe.Tag() {
+	case "required":
+		message = "is required"
+	case "email":
+		message = "must be a valid email address"
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			message = fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			message = fmt.Sprintf("must contain %s %s items", bound, fe.Param())
+		default:
+			message = fmt.Sprintf("must be %s %s", bound, fe.Param())
+		}
+	case "oneof":
+		message = "must be one of: " + fe.Param()
+	}
+	return FieldError{Field: field, Code: fe.Tag(), Message: message}
+}
+
+// 校验错误中的字段名使用 JSON 字段名
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Tracing 为每个请求创建 span，名称为路由定义；请求头中带有 traceparent 时接入上游的链路。
+// 放在 AccessLog 之前，访问日志才带有 trace_id。/metrics 的抓取不记录
+func Tracing(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return c.FullPath() != "/metrics"
+	}))
+}
@@ -0,0 +1,112 @@
+// Package openapi 提供接口的 OpenAPI 3 文档和 WebSocket/SSE 事件的 AsyncAPI 文档。
+//
+// 两份文档都是手写的 YAML，随程序一起嵌入；启动时 CheckRoutes 对照 gin 注册的路由，
+// 报告文档中缺少或多出的接口，开发模式下还可以用文档校验请求和响应（见 middleware.ValidateOpenAPI）。
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	//go:embed openapi.yaml
+	openapiYAML []byte
+	//go:embed asyncapi.yaml
+	asyncapiYAML []byte
+)
+
+var (
+	// Doc 解析后的 OpenAPI 文档，Init 之后可用
+	Doc *openapi3.T
+	// Router 按文档匹配请求对应的接口，用于校验
+	Router routers.Router
+
+	openapiJSON  []byte
+	asyncapiJSON []byte
+)
+
+// undocumented 不在 OpenAPI 文档中的路由：文档本身和 Prometheus 指标
+var undocumented = []string{"/api/openapi.json", "/api/asyncapi.json", "/api/docs/*any", "/metrics"}
+
+// Init 解析并校验嵌入的文档
+func Init() error {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openapiYAML)
+	if err != nil {
+		return fmt.Errorf("parse openapi.yaml: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return fmt.Errorf("invalid openapi.yaml: %w", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return fmt.Errorf("build openapi router: %w", err)
+	}
+	if openapiJSON, err = doc.MarshalJSON(); err != nil {
+		return err
+	}
+
+	var async map[string]interface{}
+	if err := yaml.Unmarshal(asyncapiYAML, &async); err != nil {
+		return fmt.Errorf("parse asyncapi.yaml: %w", err)
+	}
+	if asyncapiJSON, err = json.Marshal(async); err != nil {
+		return err
+	}
+
+	Doc, Router = doc, router
+	return nil
+}
+
+// ServeOpenAPI 返回 OpenAPI 文档（JSON）
+func ServeOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openapiJSON)
+}
+
+// ServeAsyncAPI 返回 AsyncAPI 文档（JSON）
+func ServeAsyncAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", asyncapiJSON)
+}
+
+// ginParam gin 路由中的 :name 参数
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// CheckRoutes 对照 gin 的路由和文档，返回文档中缺少的接口和文档中多出的接口，例如 "GET /api/todos/{id}"
+func CheckRoutes(routes gin.RoutesInfo) (missing, stale []string) {
+	documented := make(map[string]bool)
+	for path, item := range Doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	for _, route := range routes {
+		if slices.Contains(undocumented, route.Path) {
+			continue
+		}
+		key := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		if documented[key] {
+			delete(documented, key)
+		} else if route.Method != http.MethodOptions && route.Method != http.MethodHead {
+			missing = append(missing, key)
+		}
+	}
+	for key := range documented {
+		stale = append(stale, key)
+	}
+	slices.Sort(missing)
+	slices.Sort(stale)
+	return missing, stale
+}
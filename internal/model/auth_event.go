@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// 认证审计事件类型
+const (
+	AuthEventLoginSucceeded     = "login_succeeded"
+	AuthEventLoginFailed        = "login_failed"
+	AuthEventLoginLocked        = "login_locked"
+	AuthEventTwoFactorChallenge = "two_factor_challenged"
+	AuthEventTwoFactorSucceeded = "two_factor_succeeded"
+	AuthEventTwoFactorFailed    = "two_factor_failed"
+)
+
+// AuthEvent 认证审计记录，登录失败时 UserID 可能为空（邮箱未注册）
+type AuthEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Email     string    `gorm:"index" json:"email"`
+	Event     string    `gorm:"not null" json:"event"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
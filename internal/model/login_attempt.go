@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+)
+
+// LoginAttempt 一个账号或来源 IP 的连续登录失败记录，Subject 带有 "account:" 或 "ip:" 前缀
+type LoginAttempt struct {
+	Subject     string     `gorm:"primaryKey"`
+	Failures    int        `gorm:"not null"`
+	LastFailure time.Time  `gorm:"not null;index"`
+	LockedUntil *time.Time // 为空表示未锁定过
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// Session 一次登录产生的会话，访问 Token 通过 sid 关联到会话，撤销会话即可让 Token 失效
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Active 会话是否仍然有效
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
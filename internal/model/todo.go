@@ -1,7 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"strconv"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Todo struct {
@@ -17,10 +21,35 @@ type Todo struct {
 	UpdatedAt   time.Time  `json:"updated_at"`
 	RepeatType  string     `json:"repeat_type"`
 	Note        string     `json:"note"`
+	// 软删除，保留记录以便增量同步时告知客户端哪些任务被删除
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	User User `gorm:"foreignKey:UserID" json:"-"`
 }
 
+// Cursor 任务最后一次变更（包括删除）的位置，为定长的微秒时间戳（与 PostgreSQL 的精度一致），可以按字符串比较先后
+func (t Todo) Cursor() string {
+	changed := t.UpdatedAt
+	if t.DeletedAt.Valid && t.DeletedAt.Time.After(changed) {
+		changed = t.DeletedAt.Time
+	}
+	return FormatCursor(changed)
+}
+
+// FormatCursor 把时间格式化为同步游标
+func FormatCursor(t time.Time) string {
+	return fmt.Sprintf("%016d", t.UnixMicro())
+}
+
+// ParseCursor 解析 FormatCursor 生成的游标
+func ParseCursor(cursor string) (time.Time, error) {
+	micros, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || micros < 0 {
+		return time.Time{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return time.UnixMicro(micros), nil
+}
+
 type TodoCreate struct {
 	Title       string     `json:"title" binding:"required"`
 	Description string     `json:"description"`
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// Personal Access Token 的权限范围
+const (
+	ScopeTodosRead  = "todos:read"
+	ScopeTodosWrite = "todos:write"
+	ScopeListsAdmin = "lists:admin"
+)
+
+// Scopes 所有可授予 Personal Access Token 的权限范围
+var Scopes = []string{ScopeTodosRead, ScopeTodosWrite, ScopeListsAdmin}
+
+// PersonalAccessToken 供脚本和集成使用的长期 Token，只保存 SHA-256 哈希
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // Token 明文的前几位，便于用户辨认
+	Scopes     []string   `gorm:"serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// HasScope 检查 Token 是否被授予指定权限
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -14,11 +15,29 @@ type User struct {
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 两步验证（TOTP）
+	TOTPSecret   string `json:"-"` // 开启流程中即写入，确认后才生效
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"two_factor_enabled"`
+	TOTPLastStep int64  `json:"-"` // 最近一次使用的时间步，防止验证码重放
+}
+
+// RecoveryCode 两步验证的一次性恢复码，只保存哈希
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 // BeforeSave 在保存前加密密码
 func (u *User) BeforeSave(tx *gorm.DB) (err error) {
 	if u.Password != "" {
+		// 已经是 bcrypt 哈希时跳过，避免 Save 时重复加密
+		if _, err := bcrypt.Cost([]byte(u.Password)); err == nil {
+			return nil
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return err
@@ -30,5 +49,24 @@ func (u *User) BeforeSave(tx *gorm.DB) (err error) {
 
 // CheckPassword 验证密码
 func (u *User) CheckPassword(password string) error {
+	// 没有密码的账号（第三方登录创建）同样执行一次比较，避免响应时间暴露账号类型
+	if u.Password == "" {
+		CheckDummyPassword(password)
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// CheckDummyPassword 对不存在的账号执行一次同等代价的 bcrypt 比较，
+// 使响应时间无法区分邮箱是否已注册
+func CheckDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password for timing"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
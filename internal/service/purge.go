@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/health"
+	"todo-backend/pkg/logging"
+)
+
+// TombstoneRetention 已删除任务的保留时长，由 main 按配置设置。超过后由 RunTombstonePurge 彻底删除，
+// 早于该时长的同步游标改为全量快照
+var TombstoneRetention = 30 * 24 * time.Hour
+
+// purgeInterval 清理已删除任务的间隔
+const purgeInterval = time.Hour
+
+var purger health.Heartbeat
+
+// RunTombstonePurge 启动时和之后每小时彻底删除超过保留时长的已删除任务，直到 ctx 结束
+func RunTombstonePurge(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	log := logging.For(logging.DB)
+	purge := func(now time.Time) {
+		n, err := db.Store.Todos().PurgeDeleted(ctx, now.Add(-TombstoneRetention))
+		if err != nil {
+			log.WarnContext(ctx, "Failed to purge deleted todos", "error", err)
+		} else if n > 0 {
+			log.InfoContext(ctx, "Purged deleted todos", "count", n)
+		}
+		purger.Beat()
+	}
+
+	purge(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			purge(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// TombstonePurgeCheck 就绪检查：RunTombstonePurge 连续错过两次清理时失败
+func TombstonePurgeCheck() health.Check {
+	return purger.Check(3 * purgeInterval)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+)
+
+// 快照相关的事件类型
+const (
+	EventSnapshot         = "snapshot"
+	EventSnapshotComplete = "snapshot_complete"
+)
+
+// snapshotBatchSize 每条 snapshot 消息最多包含的任务数
+const snapshotBatchSize = 100
+
+// Snapshot 连接建立时发送给客户端的全量或增量数据
+type Snapshot struct {
+	Todos   []model.Todo
+	Deleted []uint // 增量同步时，自 since 以来被删除的任务
+	Cursor  string // 客户端下次增量同步使用的游标
+	// Full 是否为全量快照，客户端应丢弃快照中没有的本地任务
+	Full bool
+
+	cursors map[uint]string // 快照中每个任务的游标，用于过滤重复的实时事件
+}
+
+type snapshotComplete struct {
+	Cursor string `json:"cursor"`
+	Full   bool   `json:"full"`
+}
+
+type snapshotBatch struct {
+	Todos   []model.Todo `json:"todos"`
+	Deleted []uint       `json:"deleted"`
+}
+
+// TakeSnapshot 读取用户的任务。since 为空时返回全部任务，否则只返回此后变更或删除的任务；
+// since 早于 TombstoneRetention 时，期间删除的任务可能已被清理，同样返回全部任务
+func TakeSnapshot(ctx context.Context, userID uint, since string) (*Snapshot, error) {
+	snapshot := &Snapshot{Cursor: since, cursors: make(map[uint]string)}
+
+	var changedSince time.Time
+	if since != "" {
+		t, err := model.ParseCursor(since)
+		if err != nil {
+			return nil, err
+		}
+		changedSince = t
+	}
+
+	var (
+		todos []model.Todo
+		err   error
+	)
+	if since == "" || time.Since(changedSince) > TombstoneRetention {
+		snapshot.Cursor, snapshot.Full = "", true
+		todos, err = db.Store.Todos().List(ctx, userID)
+	} else {
+		todos, err = db.Store.Todos().ChangedSince(ctx, userID, changedSince)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, todo := range todos {
+		cursor := todo.Cursor()
+		snapshot.cursors[todo.ID] = cursor
+		if cursor > snapshot.Cursor {
+			snapshot.Cursor = cursor
+		}
+
+		if todo.DeletedAt.Valid {
+			snapshot.Deleted = append(snapshot.Deleted, todo.ID)
+		} else {
+			snapshot.Todos = append(snapshot.Todos, todo)
+		}
+	}
+	return snapshot, nil
+}
+
+// Messages 把快照分批编码为 snapshot 消息，最后是携带游标的 snapshot_complete
+func (s *Snapshot) Messages() [][]byte {
+	var messages [][]byte
+	todos, deleted := s.Todos, s.Deleted
+	for len(todos) > 0 || len(deleted) > 0 {
+		var batch snapshotBatch
+		batch.Todos, todos = split(todos, snapshotBatchSize)
+		batch.Deleted, deleted = split(deleted, snapshotBatchSize-len(batch.Todos))
+		if batch.Todos == nil {
+			batch.Todos = []model.Todo{}
+		}
+		if batch.Deleted == nil {
+			batch.Deleted = []uint{}
+		}
+
+		message, _ := json.Marshal(Event{Type: EventSnapshot, Data: batch})
+		messages = append(messages, message)
+	}
+
+	complete, _ := json.Marshal(Event{Type: EventSnapshotComplete, Data: snapshotComplete{Cursor: s.Cursor, Full: s.Full}})
+	return append(messages, complete)
+}
+
+// Covers 判断实时事件描述的变更是否已包含在快照中
+func (s *Snapshot) Covers(message []byte) bool {
+	var event struct {
+		Type   string `json:"type"`
+		Cursor string `json:"cursor"`
+		Data   struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message, &event); err != nil || event.Cursor == "" {
+		return false
+	}
+
+	switch event.Type {
+	case EventTodoCreated, EventTodoUpdated, EventTodoDeleted:
+		cursor, ok := s.cursors[event.Data.ID]
+		return ok && event.Cursor <= cursor
+	}
+	return false
+}
+
+func split[T any](items []T, n int) ([]T, []T) {
+	if n > len(items) {
+		n = len(items)
+	}
+	if n == 0 {
+		return nil, items
+	}
+	return items[:n], items[n:]
+}
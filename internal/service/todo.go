@@ -0,0 +1,171 @@
+// Package service 实现 REST 接口和 WebSocket 命令共用的业务逻辑，
+// 包括数据库读写和变更后的实时通知。
+package service
+
+import (
+	"context"
+	"errors"
+
+	"todo-backend/internal/model"
+	"todo-backend/pkg/db"
+	"todo-backend/pkg/metrics"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/ws"
+)
+
+var ErrTodoNotFound = errors.New("todo not found")
+
+// 事件类型
+const (
+	EventTodoCreated = "todo_created"
+	EventTodoUpdated = "todo_updated"
+	EventTodoDeleted = "todo_deleted"
+	EventTodosList   = "todos_list"
+)
+
+// Event 统一的事件结构，既是 REST 响应体，也是推送给 WebSocket 客户端的内容。
+// 任务变更事件带有 Cursor，客户端记录收到的最大值，重连时作为 since 做增量同步
+type Event struct {
+	Type   string      `json:"type"`
+	Data   interface{} `json:"data"`
+	Cursor string      `json:"cursor,omitempty"`
+}
+
+// CreateTodo 创建待办事项；如果 LocalID 对应的任务已存在则更新该任务（离线同步重放）
+func CreateTodo(ctx context.Context, userID uint, input model.TodoCreate) (Event, error) {
+	todos := db.Store.Todos()
+
+	// 检查是否存在 localID
+	if input.LocalID != "" {
+		// 如果存在 localID，尝试查找并更新任务
+		if todo, err := todos.GetByLocalID(ctx, userID, input.LocalID); err == nil {
+			todo.Title = input.Title
+			todo.Description = input.Description
+			todo.DueDate = input.DueDate
+			todo.RepeatType = input.RepeatType
+			todo.Note = input.Note
+			todo.IsCompleted = false
+			todo.IsFavorite = false
+
+			if err := todos.Update(ctx, todo); err != nil {
+				return Event{}, err
+			}
+
+			event := todoEvent(EventTodoUpdated, *todo)
+			notify(ctx, *todo, event)
+			return event, nil
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return Event{}, err
+		}
+	}
+
+	// 如果不存在 localID 或未找到任务，则创建新任务
+	todo := model.Todo{
+		UserID:      userID,
+		Title:       input.Title,
+		Description: input.Description,
+		DueDate:     input.DueDate,
+		RepeatType:  input.RepeatType,
+		Note:        input.Note,
+		IsCompleted: false,
+		IsFavorite:  false,
+		LocalID:     input.LocalID, // 保存 localID
+	}
+
+	if err := todos.Create(ctx, &todo); err != nil {
+		return Event{}, err
+	}
+	metrics.TodosCreated.Inc()
+
+	event := todoEvent(EventTodoCreated, todo)
+	notify(ctx, todo, event)
+	return event, nil
+}
+
+// UpdateTodo 更新待办事项
+func UpdateTodo(ctx context.Context, userID, todoID uint, input model.TodoCreate) (Event, error) {
+	todo, err := findTodo(ctx, userID, todoID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	todo.Title = input.Title
+	todo.Description = input.Description
+	todo.DueDate = input.DueDate
+	todo.RepeatType = input.RepeatType
+	todo.Note = input.Note
+	todo.LocalID = input.LocalID // 更新 localID
+
+	if err := db.Store.Todos().Update(ctx, todo); err != nil {
+		return Event{}, err
+	}
+
+	event := todoEvent(EventTodoUpdated, *todo)
+	notify(ctx, *todo, event)
+	return event, nil
+}
+
+// ToggleTodo 切换待办事项的完成状态
+func ToggleTodo(ctx context.Context, userID, todoID uint) (Event, error) {
+	todo, err := findTodo(ctx, userID, todoID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	todo.IsCompleted = !todo.IsCompleted
+
+	if err := db.Store.Todos().Update(ctx, todo); err != nil {
+		return Event{}, err
+	}
+	if todo.IsCompleted {
+		metrics.TodosCompleted.Inc()
+	}
+
+	// 使用 todo_updated 类型统一更新操作
+	event := todoEvent(EventTodoUpdated, *todo)
+	notify(ctx, *todo, event)
+	return event, nil
+}
+
+// DeleteTodo 删除待办事项，事件中返回完整的 todo 对象
+func DeleteTodo(ctx context.Context, userID, todoID uint) (Event, error) {
+	todo, err := findTodo(ctx, userID, todoID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err := db.Store.Todos().Delete(ctx, todo); err != nil {
+		return Event{}, err
+	}
+
+	event := todoEvent(EventTodoDeleted, *todo)
+	notify(ctx, *todo, event)
+	return event, nil
+}
+
+// ListTodos 获取用户的所有待办事项
+func ListTodos(ctx context.Context, userID uint) (Event, error) {
+	todos, err := db.Store.Todos().List(ctx, userID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Type: EventTodosList, Data: todos}, nil
+}
+
+func todoEvent(eventType string, todo model.Todo) Event {
+	return Event{Type: eventType, Data: todo, Cursor: todo.Cursor()}
+}
+
+func findTodo(ctx context.Context, userID, todoID uint) (*model.Todo, error) {
+	todo, err := db.Store.Todos().Get(ctx, userID, todoID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrTodoNotFound
+	}
+	return todo, err
+}
+
+// notify 把 todo 变更推送给用户主题和该 todo 主题的订阅者
+func notify(ctx context.Context, todo model.Todo, event Event) {
+	ws.Manager.Publish(ctx, event, ws.UserTopic(todo.UserID), ws.TodoTopic(todo.ID))
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"todo-backend/pkg/ws"
+)
+
+// AuthorizeTopic 检查用户能否订阅主题：user 主题只能订阅自己的，todo 主题需要拥有该 todo
+func AuthorizeTopic(ctx context.Context, userID uint, kind string, id uint) error {
+	switch kind {
+	case ws.TopicUser:
+		if id != userID {
+			return &ws.CommandError{Code: ws.ErrCodeForbidden, Message: "Cannot subscribe to another user's topic"}
+		}
+		return nil
+
+	case ws.TopicTodo:
+		_, err := findTodo(ctx, userID, id)
+		if errors.Is(err, ErrTodoNotFound) {
+			return &ws.CommandError{Code: ws.ErrCodeTopicNotFound, Message: "Todo not found"}
+		}
+		return err
+
+	default:
+		// 清单（list）尚未实现，暂时没有可订阅的清单
+		return &ws.CommandError{Code: ws.ErrCodeTopicNotFound, Message: "Topic not found"}
+	}
+}
@@ -0,0 +1,456 @@
+// Package config 加载并校验服务配置。
+//
+// 配置按以下顺序逐层覆盖：内置默认值、配置文件（YAML 或 TOML）、环境变量（包括 .env）、命令行参数。
+// 每个字段的环境变量名见 env 标签，命令行参数名为字段在配置文件中的路径，例如 --server.addr。
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-backend/pkg/logging"
+)
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	JWT       JWTConfig       `yaml:"jwt" toml:"jwt"`
+	WebSocket WebSocketConfig `yaml:"websocket" toml:"websocket"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Login     LoginConfig     `yaml:"login" toml:"login"`
+	OAuth     OAuthConfig     `yaml:"oauth" toml:"oauth"`
+	TOTP      TOTPConfig      `yaml:"totp" toml:"totp"`
+	Sync      SyncConfig      `yaml:"sync" toml:"sync"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	OpenAPI   OpenAPIConfig   `yaml:"openapi" toml:"openapi"`
+}
+
+type ServerConfig struct {
+	// Addr 监听地址；只设置 PORT 时为 ":<PORT>"
+	Addr              string   `yaml:"addr" toml:"addr" env:"SERVER_ADDR"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay 收到停止信号后，/readyz 先返回 503 并等待该时长，再断开连接、停止接收请求
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+}
+
+type DatabaseConfig struct {
+	// Driver postgres 或 sqlite（内嵌，适合单实例自托管）
+	Driver string `yaml:"driver" toml:"driver" env:"DB_DRIVER"`
+	// Path SQLite 数据库文件，仅 sqlite 使用
+	Path string `yaml:"path" toml:"path" env:"DB_PATH"`
+	// URL 完整的连接串（postgres://...），设置后忽略下面的单项配置
+	URL      string `yaml:"url" toml:"url" env:"DB_CONNECTION" secret:"url"`
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASS" secret:"true"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+	// Migrate 启动时的迁移方式：check 在有未执行的迁移时拒绝启动，up 先执行迁移
+	Migrate string `yaml:"migrate" toml:"migrate" env:"DB_MIGRATE"`
+	// 连接池设置，0 表示不限制（MaxIdleConns 为 0 时保留 2 个空闲连接）
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	// StatementTimeout 每条语句的默认超时，0 表示不限制；超时的请求返回 504
+	StatementTimeout Duration `yaml:"statement_timeout" toml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	// RouteTimeouts 按路由覆盖 StatementTimeout，格式为 "METHOD /path=时长"，路径与路由定义一致，例如 "GET /api/todos=10s"
+	RouteTimeouts []string `yaml:"route_timeouts" toml:"route_timeouts" env:"DB_ROUTE_TIMEOUTS"`
+}
+
+type JWTConfig struct {
+	Secret         string   `yaml:"secret" toml:"secret" env:"JWT_SECRET" secret:"true"`
+	AccessTokenTTL Duration `yaml:"access_token_ttl" toml:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL"`
+}
+
+type WebSocketConfig struct {
+	// Backplane 未设置时 postgres 驱动使用 postgres，sqlite 使用 memory
+	Backplane          string `yaml:"backplane" toml:"backplane" env:"WS_BACKPLANE"`
+	SlowConsumerPolicy string `yaml:"slow_consumer_policy" toml:"slow_consumer_policy" env:"WS_SLOW_CONSUMER_POLICY"`
+	SendBuffer         int    `yaml:"send_buffer" toml:"send_buffer" env:"WS_SEND_BUFFER"`
+	BufferLimit        int    `yaml:"buffer_limit" toml:"buffer_limit" env:"WS_BUFFER_LIMIT"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins 为空时不返回 CORS 头，"*" 允许任意来源
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type LoginConfig struct {
+	MaxFailures   int      `yaml:"max_failures" toml:"max_failures" env:"LOGIN_MAX_FAILURES"`
+	IPMaxFailures int      `yaml:"ip_max_failures" toml:"ip_max_failures" env:"LOGIN_IP_MAX_FAILURES"`
+	LockoutBase   Duration `yaml:"lockout_base" toml:"lockout_base" env:"LOGIN_LOCKOUT_BASE"`
+	LockoutMax    Duration `yaml:"lockout_max" toml:"lockout_max" env:"LOGIN_LOCKOUT_MAX"`
+}
+
+// OAuthConfig 启用的提供方及其设置
+type OAuthConfig struct {
+	Providers       []string `yaml:"providers" toml:"providers" env:"OAUTH_PROVIDERS"`
+	CallbackBaseURL string   `yaml:"callback_base_url" toml:"callback_base_url" env:"OAUTH_CALLBACK_BASE_URL"`
+	// Clients 各提供方的设置，按小写名称索引。启用的提供方还会读取 OAUTH_<NAME>_<字段> 环境变量
+	// （如 OAUTH_GOOGLE_CLIENT_SECRET），覆盖配置文件中的值
+	Clients map[string]OAuthClientConfig `yaml:"clients" toml:"clients"`
+}
+
+// OAuthClientConfig 单个提供方的设置，env 标签为环境变量名中 OAUTH_<NAME>_ 之后的部分。
+// google 和 github 的端点和 Scopes 有内置默认值；其他 OIDC 提供方设置 Issuer（或 DiscoveryURL），
+// 纯 OAuth2 提供方设置 AuthURL、TokenURL 和 UserInfoURL
+type OAuthClientConfig struct {
+	ClientID     string `yaml:"client_id" toml:"client_id" env:"CLIENT_ID"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret" env:"CLIENT_SECRET" secret:"true"`
+	Issuer       string `yaml:"issuer,omitempty" toml:"issuer,omitempty" env:"ISSUER"`
+	DiscoveryURL string `yaml:"discovery_url,omitempty" toml:"discovery_url,omitempty" env:"DISCOVERY_URL"`
+	// RedirectURL 为空时使用 callback_base_url + /api/oauth/<name>/callback
+	RedirectURL string   `yaml:"redirect_url,omitempty" toml:"redirect_url,omitempty" env:"REDIRECT_URL"`
+	Scopes      []string `yaml:"scopes,omitempty" toml:"scopes,omitempty" env:"SCOPES"`
+	AuthURL     string   `yaml:"auth_url,omitempty" toml:"auth_url,omitempty" env:"AUTH_URL"`
+	TokenURL    string   `yaml:"token_url,omitempty" toml:"token_url,omitempty" env:"TOKEN_URL"`
+	UserInfoURL string   `yaml:"userinfo_url,omitempty" toml:"userinfo_url,omitempty" env:"USERINFO_URL"`
+	EmailsURL   string   `yaml:"emails_url,omitempty" toml:"emails_url,omitempty" env:"EMAILS_URL"`
+}
+
+// Client 返回提供方的设置，name 不区分大小写
+func (c OAuthConfig) Client(name string) OAuthClientConfig {
+	return c.Clients[strings.ToLower(name)]
+}
+
+// envPrefix 提供方设置的环境变量前缀，例如 OAUTH_GOOGLE_
+func envPrefix(provider string) string {
+	return "OAUTH_" + strings.ToUpper(strings.ReplaceAll(provider, "-", "_")) + "_"
+}
+
+type TOTPConfig struct {
+	Issuer string `yaml:"issuer" toml:"issuer" env:"TOTP_ISSUER"`
+}
+
+type LogConfig struct {
+	// Level 默认级别：debug、info、warn 或 error
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// Format json 或 text
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+	// Levels 按子系统覆盖级别，格式为 "子系统=级别"，例如 "db=debug" 输出全部 SQL
+	Levels []string `yaml:"levels" toml:"levels" env:"LOG_LEVELS"`
+}
+
+type MetricsConfig struct {
+	// Enabled 是否提供 Prometheus 指标（/metrics），默认关闭
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"METRICS_ENABLED"`
+	// Addr 管理端口的监听地址，例如 ":9090"，启用指标时必填。/metrics 和带组件详情的 /readyz 只在该端口提供，
+	// 不应对外暴露
+	Addr string `yaml:"addr" toml:"addr" env:"METRICS_ADDR"`
+}
+
+type TracingConfig struct {
+	// Exporter none、otlp（OTLP/HTTP）或 stdout（每个 span 一行 JSON）
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	// Endpoint OTLP 接收地址，例如 "http://localhost:4318"；为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+	Endpoint string `yaml:"endpoint" toml:"endpoint" env:"TRACING_ENDPOINT"`
+	// File stdout 导出写入的文件，为空时写入标准输出
+	File        string `yaml:"file" toml:"file" env:"TRACING_FILE"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// SyncConfig 客户端增量同步设置
+type SyncConfig struct {
+	// TombstoneRetention 已删除任务的保留时长，超过后彻底删除；早于该时长的同步游标改为发送全量快照
+	TombstoneRetention Duration `yaml:"tombstone_retention" toml:"tombstone_retention" env:"SYNC_TOMBSTONE_RETENTION"`
+}
+
+// OpenAPIConfig 接口文档设置
+type OpenAPIConfig struct {
+	// Docs 是否在 /api/docs/ 提供接口文档页面；/api/openapi.json 和 /api/asyncapi.json 始终提供
+	Docs bool `yaml:"docs" toml:"docs" env:"OPENAPI_DOCS"`
+	// Validate 按文档校验请求（不符合时返回 400）和响应（不符合时记录错误日志），用于开发和测试环境
+	Validate bool `yaml:"validate" toml:"validate" env:"OPENAPI_VALIDATE"`
+}
+
+// Default 返回内置默认值
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:  "postgres",
+			Path:    "todo.db",
+			Port:    5432,
+			SSLMode: "disable",
+			Migrate: "check",
+
+			MaxOpenConns:     25,
+			MaxIdleConns:     10,
+			ConnMaxLifetime:  Duration(30 * time.Minute),
+			ConnMaxIdleTime:  Duration(5 * time.Minute),
+			StatementTimeout: Duration(5 * time.Second),
+		},
+		JWT: JWTConfig{
+			AccessTokenTTL: Duration(7 * 24 * time.Hour),
+		},
+		WebSocket: WebSocketConfig{
+			SlowConsumerPolicy: "drop_oldest",
+			SendBuffer:         256,
+			BufferLimit:        4096,
+		},
+		CORS: CORSConfig{
+			MaxAge: Duration(12 * time.Hour),
+		},
+		Login: LoginConfig{
+			MaxFailures:   5,
+			IPMaxFailures: 20,
+			LockoutBase:   Duration(30 * time.Second),
+			LockoutMax:    Duration(15 * time.Minute),
+		},
+		TOTP: TOTPConfig{
+			Issuer: "Todo App",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "todo-backend",
+		},
+		Sync: SyncConfig{
+			TombstoneRetention: Duration(30 * 24 * time.Hour),
+		},
+		OpenAPI: OpenAPIConfig{
+			Docs: true,
+		},
+	}
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// minJWTSecretLength HS256 密钥的最小长度
+const minJWTSecretLength = 32
+
+// Validate 检查所有配置项，一次返回全部问题
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay (SHUTDOWN_DELAY) must not be negative")
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"jwt.access_token_ttl", c.JWT.AccessTokenTTL},
+		{"login.lockout_base", c.Login.LockoutBase},
+		{"login.lockout_max", c.Login.LockoutMax},
+	} {
+		check(d.value > 0, "%s must be positive", d.name)
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.URL != "" {
+			u, err := url.Parse(c.Database.URL)
+			check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"),
+				"database.url (DB_CONNECTION) must be a postgres:// URL")
+		} else {
+			check(c.Database.Host != "", "database.host (DB_HOST) is required")
+			check(c.Database.User != "", "database.user (DB_USER) is required")
+			check(c.Database.Name != "", "database.name (DB_NAME) is required")
+			check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port (DB_PORT) must be between 1 and 65535")
+			check(sslModes[c.Database.SSLMode], "database.sslmode (DB_SSLMODE) %q is not a valid sslmode", c.Database.SSLMode)
+		}
+	case "sqlite":
+		check(c.Database.Path != "", "database.path (DB_PATH) is required for the sqlite driver")
+		check(c.WebSocket.Backplane != "postgres", "websocket.backplane (WS_BACKPLANE) postgres requires database.driver postgres")
+	default:
+		errs = append(errs, fmt.Errorf("database.driver (DB_DRIVER) %q must be postgres or sqlite", c.Database.Driver))
+	}
+
+	check(c.Database.Migrate == "check" || c.Database.Migrate == "up",
+		"database.migrate (DB_MIGRATE) %q must be check or up", c.Database.Migrate)
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns (DB_MAX_OPEN_CONNS) must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns (DB_MAX_IDLE_CONNS) must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns (DB_MAX_IDLE_CONNS) must not be larger than max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime (DB_CONN_MAX_LIFETIME) must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time (DB_CONN_MAX_IDLE_TIME) must not be negative")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout (DB_STATEMENT_TIMEOUT) must not be negative")
+	if _, err := c.Database.StatementTimeouts(); err != nil {
+		errs = append(errs, err)
+	}
+
+	check(c.JWT.Secret != "", "jwt.secret (JWT_SECRET) is required")
+	check(c.JWT.Secret == "" || len(c.JWT.Secret) >= minJWTSecretLength,
+		"jwt.secret (JWT_SECRET) must be at least %d characters", minJWTSecretLength)
+
+	switch c.WebSocket.Backplane {
+	case "postgres", "memory", "none":
+	default:
+		errs = append(errs, fmt.Errorf("websocket.backplane (WS_BACKPLANE) %q must be postgres, memory or none", c.WebSocket.Backplane))
+	}
+	switch c.WebSocket.SlowConsumerPolicy {
+	case "drop_oldest", "disconnect", "buffer":
+	default:
+		errs = append(errs, fmt.Errorf("websocket.slow_consumer_policy (WS_SLOW_CONSUMER_POLICY) %q must be drop_oldest, disconnect or buffer", c.WebSocket.SlowConsumerPolicy))
+	}
+	check(c.WebSocket.SendBuffer > 0, "websocket.send_buffer (WS_SEND_BUFFER) must be positive")
+	check(c.WebSocket.BufferLimit >= c.WebSocket.SendBuffer, "websocket.buffer_limit (WS_BUFFER_LIMIT) must not be smaller than send_buffer")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allow_credentials cannot be used with allowed_origins \"*\"")
+			continue
+		}
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"cors.allowed_origins: %q is not an origin (scheme://host[:port])", origin)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.Login.MaxFailures > 0, "login.max_failures (LOGIN_MAX_FAILURES) must be positive")
+	check(c.Login.IPMaxFailures > 0, "login.ip_max_failures (LOGIN_IP_MAX_FAILURES) must be positive")
+	check(c.Login.LockoutMax >= c.Login.LockoutBase, "login.lockout_max must not be smaller than lockout_base")
+
+	if len(c.OAuth.Providers) > 0 {
+		u, err := url.Parse(c.OAuth.CallbackBaseURL)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			"oauth.callback_base_url (OAUTH_CALLBACK_BASE_URL) is required when OAuth providers are enabled")
+	}
+	for _, name := range c.OAuth.Providers {
+		name = strings.ToLower(name)
+		client := c.OAuth.Client(name)
+		check(client.ClientID != "", "oauth.clients.%s.client_id (%sCLIENT_ID) is required for an enabled provider", name, envPrefix(name))
+		check(client.ClientSecret != "", "oauth.clients.%s.client_secret (%sCLIENT_SECRET) is required for an enabled provider", name, envPrefix(name))
+	}
+
+	check(c.Sync.TombstoneRetention > 0, "sync.tombstone_retention (SYNC_TOMBSTONE_RETENTION) must be positive")
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level (LOG_LEVEL) %q must be debug, info, warn or error", c.Log.Level))
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format (LOG_FORMAT) %q must be json or text", c.Log.Format)
+	if _, err := c.Log.SubsystemLevels(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Metrics.Enabled {
+		check(c.Metrics.Addr != "", "metrics.addr (METRICS_ADDR) is required when metrics are enabled")
+		check(c.Metrics.Addr != c.Server.Addr, "metrics.addr (METRICS_ADDR) must differ from server.addr")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter (TRACING_EXPORTER) %q must be none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.Endpoint != "" {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.endpoint (TRACING_ENDPOINT) must be an http:// or https:// URL")
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name (OTEL_SERVICE_NAME) is required")
+
+	return errors.Join(errs...)
+}
+
+// derive 补全取决于其他配置项的默认值，在所有配置层应用之后调用
+func (c *Config) derive() {
+	if c.WebSocket.Backplane == "" {
+		c.WebSocket.Backplane = "memory"
+		if c.Database.Driver == "postgres" {
+			c.WebSocket.Backplane = "postgres"
+		}
+	}
+}
+
+// DSN 数据库连接串。sqlite 为文件路径；postgres 优先使用 URL，否则由单项配置拼出 key=value 形式
+func (d DatabaseConfig) DSN() string {
+	if d.Driver == "sqlite" {
+		return d.Path
+	}
+	if d.URL != "" {
+		return d.URL
+	}
+
+	parts := []string{
+		"host=" + quoteDSN(d.Host),
+		"user=" + quoteDSN(d.User),
+		"dbname=" + quoteDSN(d.Name),
+		fmt.Sprintf("port=%d", d.Port),
+		"sslmode=" + quoteDSN(d.SSLMode),
+	}
+	if d.Password != "" {
+		parts = append(parts, "password="+quoteDSN(d.Password))
+	}
+	return strings.Join(parts, " ")
+}
+
+// StatementTimeouts 解析 RouteTimeouts，键为 "METHOD /path"
+func (d DatabaseConfig) StatementTimeouts() (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration, len(d.RouteTimeouts))
+	for _, entry := range d.RouteTimeouts {
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || !hasPath || !strings.HasPrefix(strings.TrimSpace(path), "/") || err != nil || timeout < 0 {
+			return nil, fmt.Errorf("database.route_timeouts (DB_ROUTE_TIMEOUTS): %q must look like \"GET /api/todos=10s\"", entry)
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = timeout
+	}
+	return routes, nil
+}
+
+// SubsystemLevels 解析 Levels
+func (l LogConfig) SubsystemLevels() (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level, len(l.Levels))
+	for _, entry := range l.Levels {
+		name, value, _ := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !slices.Contains(logging.Subsystems, name) {
+			return nil, fmt.Errorf("log.levels (LOG_LEVELS): %q is not a subsystem, must be one of %s", name, strings.Join(logging.Subsystems, ", "))
+		}
+		level, err := logging.ParseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("log.levels (LOG_LEVELS): %q: %w", entry, err)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// quoteDSN 按 libpq 的规则转义包含空格或引号的值
+func quoteDSN(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration 在配置文件和环境变量中以 "30s"、"15m" 形式书写
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Options 命令行中与配置内容无关的选项
+type Options struct {
+	File        string // --config / CONFIG_FILE
+	PrintConfig bool   // --print-config：打印最终配置（隐去密钥）后退出
+}
+
+// Load 依次应用默认值、配置文件、环境变量和命令行参数，并校验结果。
+// 当前目录下的 .env 文件可选，其中的变量不会覆盖已有的环境变量
+func Load(args []string) (*Config, Options, error) {
+	var opts Options
+	cfg := Default()
+
+	// 先解析命令行，得到配置文件路径；参数值在最后一层应用
+	fset := flag.NewFlagSet("todo-backend", flag.ContinueOnError)
+	fset.StringVar(&opts.File, "config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fset.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	overrides := make(map[string]string)
+	var order []string
+	walk(reflect.ValueOf(cfg).Elem(), "", func(_ reflect.Value, field reflect.StructField, path string) {
+		usage := "config " + path
+		if env := field.Tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+		fset.Func(path, usage, func(v string) error {
+			overrides[path] = v
+			order = append(order, path)
+			return nil
+		})
+	})
+	if err := fset.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, opts, fmt.Errorf("load .env: %w", err)
+	}
+
+	if opts.File == "" {
+		opts.File = os.Getenv("CONFIG_FILE")
+	}
+	if opts.File != "" {
+		if err := loadFile(cfg, opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	var errs []error
+	errs = append(errs, applyEnv(cfg)...)
+
+	values := make(map[string]reflect.Value)
+	walk(reflect.ValueOf(cfg).Elem(), "", func(v reflect.Value, _ reflect.StructField, path string) {
+		values[path] = v
+	})
+	for _, path := range order {
+		if err := setValue(values[path], overrides[path]); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", path, err))
+		}
+	}
+	// 启用的提供方由前面各层确定后，再读取它们的环境变量
+	errs = append(errs, applyOAuthEnv(&cfg.OAuth)...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, opts, err
+	}
+	cfg.derive()
+	return cfg, opts, cfg.Validate()
+}
+
+// loadFile 按扩展名解析 YAML 或 TOML，未知的配置项视为错误
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// applyEnv 用 env 标签对应的环境变量覆盖配置
+func applyEnv(cfg *Config) []error {
+	var errs []error
+	walk(reflect.ValueOf(cfg).Elem(), "", func(v reflect.Value, field reflect.StructField, path string) {
+		name := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if name == "" || !ok || raw == "" {
+			return
+		}
+		if err := setValue(v, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	})
+
+	// README 中的 PORT 只指定端口
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("SERVER_ADDR") == "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			errs = append(errs, fmt.Errorf("PORT: %q is not a number", port))
+		} else {
+			cfg.Server.Addr = ":" + port
+		}
+	}
+	return errs
+}
+
+// applyOAuthEnv 用 OAUTH_<NAME>_<字段> 环境变量覆盖启用的提供方的设置
+func applyOAuthEnv(cfg *OAuthConfig) []error {
+	// 配置文件中的名称可能带大写，统一为小写以便按提供方名称查找
+	clients := make(map[string]OAuthClientConfig, len(cfg.Clients))
+	for name, client := range cfg.Clients {
+		clients[strings.ToLower(name)] = client
+	}
+	cfg.Clients = clients
+
+	var errs []error
+	for _, name := range cfg.Providers {
+		name = strings.ToLower(name)
+		client := cfg.Client(name)
+		walk(reflect.ValueOf(&client).Elem(), "", func(v reflect.Value, field reflect.StructField, _ string) {
+			key := envPrefix(name) + field.Tag.Get("env")
+			raw, ok := os.LookupEnv(key)
+			if !ok || raw == "" {
+				return
+			}
+			if field.Name == "Scopes" {
+				// Scopes 在 OAuth 中以空格分隔，两种写法都接受
+				raw = strings.Join(strings.Fields(raw), ",")
+			}
+			if err := setValue(v, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		})
+		cfg.Clients[name] = client
+	}
+	return errs
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// walk 遍历配置中的所有叶子字段，path 为配置文件中的路径（如 server.addr）。
+// 按名称索引的设置（oauth.clients）不是固定的字段，没有对应的命令行参数，由 applyOAuthEnv 和 Print 单独处理
+func walk(v reflect.Value, prefix string, fn func(v reflect.Value, field reflect.StructField, path string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Struct:
+			walk(fv, path, fn)
+			continue
+		case reflect.Map:
+			continue
+		}
+		fn(fv, field, path)
+	}
+}
+
+// setValue 把字符串形式的值写入字段，列表以逗号分隔
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		return v.Addr().Interface().(*Duration).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Print 以 YAML 输出配置，secret 标签标记的字段被隐去
+func (c *Config) Print(w io.Writer) error {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+
+	// map 与 c 共用，替换为隐去密钥的副本
+	redacted.OAuth.Clients = make(map[string]OAuthClientConfig, len(c.OAuth.Clients))
+	for name, client := range c.OAuth.Clients {
+		redact(reflect.ValueOf(&client).Elem())
+		redacted.OAuth.Clients[name] = client
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&redacted)
+}
+
+// redact 隐去 v 中 secret 标签标记的字段
+func redact(v reflect.Value) {
+	walk(v, "", func(v reflect.Value, field reflect.StructField, _ string) {
+		switch field.Tag.Get("secret") {
+		case "true":
+			if v.String() != "" {
+				v.SetString("REDACTED")
+			}
+		case "url":
+			if u, err := url.Parse(v.String()); err == nil && v.String() != "" {
+				v.SetString(u.Redacted())
+			}
+		}
+	})
+}
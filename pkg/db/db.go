@@ -0,0 +1,84 @@
+package db
+
+import (
+	"time"
+
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/storage/gormstore"
+	"todo-backend/pkg/storage/postgres"
+	"todo-backend/pkg/storage/sqlite"
+
+	"gorm.io/gorm"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
+)
+
+// Store 业务数据的存储；迁移、健康检查等需要底层连接池时使用 Store.SQL
+var Store storage.Store
+
+// Options 连接池和语句超时设置。零值沿用 database/sql 的默认值：不限制连接数和存活时间，保留 2 个空闲连接
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout 每条语句的默认超时（0 不限制），可按请求通过 storage.WithStatementTimeout 覆盖
+	StatementTimeout time.Duration
+}
+
+// InitDB 按 driver（postgres 或 sqlite）连接数据库。postgres 的 dsn 由 config.DatabaseConfig.DSN 生成，
+// sqlite 的 dsn 为数据库文件路径。表结构由 migrations 中的 SQL 迁移管理
+func InitDB(driver, dsn string, opts Options) {
+	log := logging.For(logging.DB)
+	log.Info("Connecting to database", "driver", driver)
+
+	config := &gorm.Config{
+		Logger: newGormLogger(),
+	}
+
+	var (
+		conn *gorm.DB
+		err  error
+	)
+	switch driver {
+	case postgres.Driver:
+		conn, err = postgres.Open(dsn, config)
+	case sqlite.Driver:
+		conn, err = sqlite.Open(dsn, config)
+	default:
+		logging.Fatal(log, "Unknown database driver", "driver", driver)
+	}
+	if err != nil {
+		logging.Fatal(log, "Failed to connect to database", "error", err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		logging.Fatal(log, "Failed to configure connection pool", "error", err)
+	}
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	if err := gormstore.RegisterTimeouts(conn, opts.StatementTimeout); err != nil {
+		logging.Fatal(log, "Failed to register statement timeouts", "error", err)
+	}
+	// 每条语句一个 span，与日志一样只记录占位符；连接池指标已由 metrics.RegisterDB 导出
+	if err := conn.Use(otelgorm.NewPlugin(otelgorm.WithoutQueryVariables(), otelgorm.WithoutMetrics())); err != nil {
+		logging.Fatal(log, "Failed to register database tracing", "error", err)
+	}
+	Store = gormstore.New(conn)
+
+	log.Info("Successfully connected to database")
+}
+
+// Close 关闭数据库连接池
+func Close() error {
+	if Store == nil {
+		return nil
+	}
+	return Store.Close()
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todo-backend/pkg/logging"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold 超过该耗时的语句以 warn 级别记录
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger 把 GORM 的日志写入 db 子系统：SQL 为 debug 级别，慢查询为 warn，失败为 error。
+// SQL 只记录占位符，不记录参数，避免密码哈希、Token 等写入日志
+type gormLogger struct {
+	log *slog.Logger
+}
+
+func newGormLogger() logger.Interface {
+	return gormLogger{log: logging.For(logging.DB)}
+}
+
+// LogMode 级别由 db 子系统的配置决定，这里忽略
+func (l gormLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.log.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.log.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.log.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	var level slog.Level
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = slog.LevelError
+	case elapsed > slowQueryThreshold:
+		level = slog.LevelWarn
+	default:
+		level = slog.LevelDebug
+	}
+	if !l.log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{
+		"sql", sql,
+		"rows", rows,
+		"duration_ms", float64(elapsed.Microseconds()) / 1000,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	l.log.Log(ctx, level, "query", attrs...)
+}
+
+// ParamsFilter 去掉参数，日志中的 SQL 只保留占位符
+func (l gormLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
@@ -0,0 +1,24 @@
+// Package migrations 内嵌按序号命名的 SQL 迁移文件：<driver>/NNNN_<name>.up.sql 与 <driver>/NNNN_<name>.down.sql。
+// postgres 和 sqlite 各有一套，同一版本号的迁移在两边表达同样的变更。
+// 新迁移用 `todo-backend migrate create <name>` 生成，已发布的迁移文件不要再修改。
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
+
+// Dir 迁移文件在源码树中的位置，migrate create 在其下每个驱动的目录中生成文件
+const Dir = "pkg/db/migrations"
+
+// Drivers 提供迁移文件的数据库驱动
+var Drivers = []string{"postgres", "sqlite"}
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// For 返回 driver 对应的迁移文件
+func For(driver string) (fs.FS, error) {
+	return fs.Sub(files, path.Clean(driver))
+}
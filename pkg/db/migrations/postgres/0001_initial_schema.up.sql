@@ -0,0 +1,106 @@
+-- 初始表结构，与此前 AutoMigrate 创建的结构一致。
+-- 全部使用 IF NOT EXISTS，已由 AutoMigrate 建好表的数据库可以直接执行。
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    email text NOT NULL,
+    password text NOT NULL,
+    name text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    totp_secret text,
+    totp_enabled boolean NOT NULL DEFAULT false,
+    totp_last_step bigint,
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+    id bigserial PRIMARY KEY,
+    local_id text,
+    user_id bigint,
+    title text,
+    description text,
+    due_date timestamptz,
+    is_completed boolean,
+    is_favorite boolean,
+    created_at timestamptz,
+    updated_at timestamptz,
+    repeat_type text,
+    note text,
+    CONSTRAINT fk_todos_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+-- 软删除列是后加的，旧库中的 todos 表可能没有
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_todos_deleted_at ON todos (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS identities (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_identities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    prefix text NOT NULL,
+    scopes text,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    CONSTRAINT fk_personal_access_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    user_agent text,
+    ip text,
+    created_at timestamptz,
+    last_seen_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS auth_events (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    email text,
+    event text NOT NULL,
+    ip text,
+    user_agent text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_auth_events_user_id ON auth_events (user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_email ON auth_events (email);
+CREATE INDEX IF NOT EXISTS idx_auth_events_created_at ON auth_events (created_at);
+
+-- WebSocket backplane 中超过 NOTIFY 长度上限的事件
+CREATE TABLE IF NOT EXISTS ws_backplane_messages (
+    id text PRIMARY KEY,
+    payload text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
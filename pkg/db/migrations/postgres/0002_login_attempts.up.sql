@@ -0,0 +1,8 @@
+-- 登录失败计数，所有实例共享，subject 为 "account:<邮箱>" 或 "ip:<地址>"
+CREATE TABLE login_attempts (
+    subject text PRIMARY KEY,
+    failures integer NOT NULL DEFAULT 0,
+    last_failure timestamptz NOT NULL,
+    locked_until timestamptz
+);
+CREATE INDEX idx_login_attempts_last_failure ON login_attempts (last_failure);
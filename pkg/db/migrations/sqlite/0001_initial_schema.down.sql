@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS auth_events;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS users;
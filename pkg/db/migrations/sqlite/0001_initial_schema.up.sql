@@ -0,0 +1,97 @@
+-- 初始表结构，与 postgres/0001_initial_schema.up.sql 对应。
+-- 时间以 UTC 文本保存，布尔值为 0/1。
+
+CREATE TABLE users (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    email text NOT NULL,
+    password text NOT NULL,
+    name text,
+    created_at datetime,
+    updated_at datetime,
+    totp_secret text,
+    totp_enabled numeric NOT NULL DEFAULT false,
+    totp_last_step integer,
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+
+CREATE TABLE todos (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    local_id text,
+    user_id integer,
+    title text,
+    description text,
+    due_date datetime,
+    is_completed numeric,
+    is_favorite numeric,
+    created_at datetime,
+    updated_at datetime,
+    repeat_type text,
+    note text,
+    deleted_at datetime,
+    CONSTRAINT fk_todos_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_todos_deleted_at ON todos (deleted_at);
+
+CREATE TABLE recovery_codes (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL,
+    code_hash text NOT NULL,
+    used_at datetime,
+    created_at datetime
+);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE identities (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at datetime,
+    updated_at datetime,
+    CONSTRAINT fk_identities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_identities_user_id ON identities (user_id);
+CREATE UNIQUE INDEX idx_identity_provider_subject ON identities (provider, subject);
+
+CREATE TABLE personal_access_tokens (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    prefix text NOT NULL,
+    scopes text,
+    expires_at datetime,
+    last_used_at datetime,
+    revoked_at datetime,
+    created_at datetime,
+    CONSTRAINT fk_personal_access_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE UNIQUE INDEX idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+
+CREATE TABLE sessions (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL,
+    user_agent text,
+    ip text,
+    created_at datetime,
+    last_seen_at datetime,
+    expires_at datetime NOT NULL,
+    revoked_at datetime,
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE auth_events (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer,
+    email text,
+    event text NOT NULL,
+    ip text,
+    user_agent text,
+    created_at datetime
+);
+CREATE INDEX idx_auth_events_user_id ON auth_events (user_id);
+CREATE INDEX idx_auth_events_email ON auth_events (email);
+CREATE INDEX idx_auth_events_created_at ON auth_events (created_at);
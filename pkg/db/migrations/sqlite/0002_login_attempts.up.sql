@@ -0,0 +1,8 @@
+-- 登录失败计数，与 postgres/0002_login_attempts.up.sql 对应
+CREATE TABLE login_attempts (
+    subject text PRIMARY KEY,
+    failures integer NOT NULL DEFAULT 0,
+    last_failure datetime NOT NULL,
+    locked_until datetime
+);
+CREATE INDEX idx_login_attempts_last_failure ON login_attempts (last_failure);
@@ -1,57 +0,0 @@
-package db
-
-import (
-	"fmt"
-	"log"
-	"os"
-
-	"todo-backend/internal/model"
-
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
-
-var DB *gorm.DB
-
-func InitDB() {
-	// 构建数据库连接字符串
-	dsn := fmt.Sprintf("host=%s user=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-
-	log.Printf("Attempting to connect to database with DSN: %s", dsn)
-
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-
-	// 测试连接
-	sqlDB, err := DB.DB()
-	if err != nil {
-		log.Fatal("Failed to get database instance:", err)
-	}
-
-	// Ping 数据库
-	err = sqlDB.Ping()
-	if err != nil {
-		log.Fatal("Failed to ping database:", err)
-	}
-
-	log.Println("Successfully connected to database")
-
-	// 数据库迁移
-	err = DB.AutoMigrate(&model.User{}, &model.Todo{})
-	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
-	}
-
-	log.Println("Database migration completed")
-}
@@ -0,0 +1,162 @@
+// Package health 汇总各组件的状态，供 /readyz 就绪探针使用。
+//
+// 每个组件注册一个检查函数，Report 并发执行全部检查；任一组件失败或进程开始优雅关闭后，
+// 服务即视为未就绪，负载均衡和编排系统据此停止转发新请求。
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"todo-backend/pkg/logging"
+)
+
+// 状态
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// checkTimeout 单个检查的超时时间，超时视为失败
+const checkTimeout = 2 * time.Second
+
+// Check 检查一个组件，返回的 detail（可以为 nil）原样出现在报告中
+type Check func(ctx context.Context) (detail interface{}, err error)
+
+// Component 单个组件的检查结果
+type Component struct {
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Detail     interface{} `json:"detail,omitempty"`
+	DurationMS float64     `json:"duration_ms"`
+}
+
+// Report 就绪检查的结果
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// Ready 是否就绪
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Summary 只保留整体和各组件状态的报告，去掉错误信息和 detail，用于对外的探针
+func (r Report) Summary() Report {
+	summary := Report{Status: r.Status, Components: make(map[string]Component, len(r.Components))}
+	for name, component := range r.Components {
+		summary.Components[name] = Component{Status: component.Status, DurationMS: component.DurationMS}
+	}
+	return summary
+}
+
+// Checker 已注册的组件检查
+type Checker struct {
+	mutex        sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Register 注册组件检查，同名时覆盖
+func (h *Checker) Register(name string, check Check) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks[name] = check
+}
+
+// ShutDown 标记进程开始优雅关闭，此后 Report 始终为未就绪
+func (h *Checker) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Report 并发执行所有检查
+func (h *Checker) Report(ctx context.Context) Report {
+	h.mutex.RLock()
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mutex.RUnlock()
+
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+	report := Report{Status: StatusOK, Components: make(map[string]Component, len(checks))}
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			component := run(ctx, check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Components[name] = component
+			if component.Status != StatusOK {
+				report.Status = StatusFailing
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) Component {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+	component := Component{
+		Status:     StatusOK,
+		Detail:     detail,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		component.Status = StatusFailing
+		component.Error = logging.Redact(err.Error())
+	}
+	return component
+}
+
+// ErrStale 后台任务超过预期时间没有运行
+var ErrStale = errors.New("background job has not run recently")
+
+// Heartbeat 记录后台任务最近一次运行的时间
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// Beat 记录一次运行
+func (b *Heartbeat) Beat() {
+	b.last.Store(time.Now().UnixNano())
+}
+
+// Check 最近一次运行距今超过 maxAge（或从未运行）时失败
+func (b *Heartbeat) Check(maxAge time.Duration) Check {
+	return func(context.Context) (interface{}, error) {
+		last := b.last.Load()
+		if last == 0 {
+			return nil, ErrStale
+		}
+		at := time.Unix(0, last)
+		detail := map[string]interface{}{"last_run": at.UTC().Format(time.RFC3339)}
+		if time.Since(at) > maxAge {
+			return detail, ErrStale
+		}
+		return detail, nil
+	}
+}
@@ -0,0 +1,137 @@
+// Package lockout 记录登录失败次数，连续失败超过阈值后按指数退避临时锁定。
+// 计数保存在数据库中（storage.LoginAttemptStore），多个实例共享同一份计数和阈值。
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo-backend/pkg/health"
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/storage"
+)
+
+type Config struct {
+	MaxFailures int           // 允许的连续失败次数，超过后开始锁定
+	BaseDelay   time.Duration // 第一次锁定的时长，之后每次失败翻倍
+	MaxDelay    time.Duration // 锁定时长上限
+	ResetAfter  time.Duration // 无失败超过该时长后清零计数
+}
+
+var (
+	// Accounts 按账号（邮箱）计数
+	Accounts = New("account:", Config{MaxFailures: 5, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, ResetAfter: time.Hour})
+	// IPs 按来源 IP 计数，同一出口 IP 后可能有多个用户，阈值更高
+	IPs = New("ip:", Config{MaxFailures: 20, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, ResetAfter: time.Hour})
+)
+
+// attempts 保存计数的存储，由 Init 设置
+var attempts storage.LoginAttemptStore
+
+// Tracker 失败计数器，按 key 隔离；prefix 使账号和 IP 的记录在同一张表中互不冲突
+type Tracker struct {
+	config Config
+	prefix string
+}
+
+func New(prefix string, config Config) *Tracker {
+	return &Tracker{config: config, prefix: prefix}
+}
+
+// Init 设置保存计数的存储，并按配置设置账号和 IP 的阈值，过期记录的清理由 RunJanitor 负责
+func Init(store storage.LoginAttemptStore, maxFailures, ipMaxFailures int, baseDelay, maxDelay time.Duration) {
+	attempts = store
+	Accounts.config.MaxFailures = maxFailures
+	IPs.config.MaxFailures = ipMaxFailures
+	for _, t := range []*Tracker{Accounts, IPs} {
+		t.config.BaseDelay = baseDelay
+		t.config.MaxDelay = maxDelay
+	}
+}
+
+// janitorInterval 清理过期记录的间隔
+const janitorInterval = time.Minute
+
+var (
+	janitor health.Heartbeat
+	logger  = logging.For(logging.Auth)
+)
+
+// RunJanitor 每分钟清理过期的记录，直到 ctx 结束
+func RunJanitor(ctx context.Context) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	janitor.Beat()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, t := range []*Tracker{Accounts, IPs} {
+				if err := t.Cleanup(ctx, now); err != nil {
+					logger.WarnContext(ctx, "Failed to clean up login attempts", "error", err)
+				}
+			}
+			janitor.Beat()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// JanitorCheck 就绪检查：RunJanitor 连续错过两次清理时失败
+func JanitorCheck() health.Check {
+	return janitor.Check(3 * janitorInterval)
+}
+
+// Check 返回 key 还需等待多久才能再次尝试，0 表示未被锁定
+func (t *Tracker) Check(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	attempt, err := attempts.Get(ctx, t.prefix+key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Failure 记录一次失败，若因此触发锁定则返回锁定时长
+func (t *Tracker) Failure(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	failures, err := attempts.Fail(ctx, t.prefix+key, now, now.Add(-t.config.ResetAfter))
+	if err != nil {
+		return 0, err
+	}
+
+	over := failures - t.config.MaxFailures
+	if over <= 0 {
+		return 0, nil
+	}
+
+	delay := t.config.BaseDelay
+	for i := 1; i < over && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+
+	if err := attempts.Lock(ctx, t.prefix+key, now.Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Success 登录成功后清除 key 的失败记录
+func (t *Tracker) Success(ctx context.Context, key string) error {
+	return attempts.Delete(ctx, t.prefix+key)
+}
+
+// Cleanup 删除已解锁且长时间没有失败的记录
+func (t *Tracker) Cleanup(ctx context.Context, now time.Time) error {
+	_, err := attempts.DeleteExpired(ctx, t.prefix, now, now.Add(-t.config.ResetAfter))
+	return err
+}
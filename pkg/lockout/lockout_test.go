@@ -0,0 +1,176 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/storage/gormstore"
+	"todo-backend/pkg/storage/sqlite"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// useSQLite 把计数保存到临时的 SQLite 数据库
+func useSQLite(t *testing.T) {
+	t.Helper()
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "todo.db"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	fsys, err := migrations.For(sqlite.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(sqlDB, sqlite.Driver, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := attempts
+	attempts = gormstore.New(db).LoginAttempts()
+	t.Cleanup(func() { attempts = previous })
+}
+
+var testConfig = Config{MaxFailures: 3, BaseDelay: 30 * time.Second, MaxDelay: 2 * time.Minute, ResetAfter: time.Hour}
+
+func TestTrackerBackoff(t *testing.T) {
+	useSQLite(t)
+	ctx := context.Background()
+	tracker := New("account:", testConfig)
+	start := time.Unix(1700000000, 0)
+
+	// 超过 MaxFailures 后锁定时长从 BaseDelay 开始翻倍，直到 MaxDelay
+	tests := []struct {
+		failure int
+		want    time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, 0},
+		{4, 30 * time.Second},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		now := start.Add(time.Duration(tt.failure) * time.Second)
+		wait, err := tracker.Failure(ctx, "alice@example.com", now)
+		if err != nil || wait != tt.want {
+			t.Fatalf("failure %d: Failure = %v, %v; want %v", tt.failure, wait, err, tt.want)
+		}
+		if wait, err := tracker.Check(ctx, "alice@example.com", now); err != nil || wait != tt.want {
+			t.Fatalf("failure %d: Check = %v, %v; want %v", tt.failure, wait, err, tt.want)
+		}
+	}
+
+	last := start.Add(7 * time.Second)
+	if wait, _ := tracker.Check(ctx, "alice@example.com", last.Add(time.Minute)); wait != time.Minute {
+		t.Fatalf("Check one minute into the lock = %v, want 1m", wait)
+	}
+	if wait, _ := tracker.Check(ctx, "alice@example.com", last.Add(2*time.Minute)); wait != 0 {
+		t.Fatalf("Check after the lock expired = %v, want 0", wait)
+	}
+	if wait, _ := tracker.Check(ctx, "bob@example.com", last); wait != 0 {
+		t.Fatalf("Check of another key = %v, want 0", wait)
+	}
+}
+
+func TestTrackerReset(t *testing.T) {
+	useSQLite(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	fail := func(tracker *Tracker, key string, times int) time.Duration {
+		t.Helper()
+		var wait time.Duration
+		for i := 0; i < times; i++ {
+			var err error
+			if wait, err = tracker.Failure(ctx, key, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return wait
+	}
+
+	tests := []struct {
+		name  string
+		reset func(tracker *Tracker, key string)
+	}{
+		{"success", func(tracker *Tracker, key string) {
+			if err := tracker.Success(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"no failures for ResetAfter", func(tracker *Tracker, key string) {
+			now = now.Add(testConfig.ResetAfter + time.Second)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := New("account:", testConfig)
+			key := tt.name
+			fail(tracker, key, testConfig.MaxFailures)
+			tt.reset(tracker, key)
+
+			// 清零后重新允许 MaxFailures 次失败
+			if wait := fail(tracker, key, testConfig.MaxFailures); wait != 0 {
+				t.Fatalf("locked after %d failures following a reset: %v", testConfig.MaxFailures, wait)
+			}
+			if wait := fail(tracker, key, 1); wait != testConfig.BaseDelay {
+				t.Fatalf("next failure = %v, want %v", wait, testConfig.BaseDelay)
+			}
+		})
+	}
+}
+
+func TestTrackerPrefixAndCleanup(t *testing.T) {
+	useSQLite(t)
+	ctx := context.Background()
+	accounts := New("account:", testConfig)
+	ips := New("ip:", Config{MaxFailures: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, ResetAfter: time.Hour})
+	now := time.Unix(1700000000, 0)
+
+	// 同一个 key 在两个 Tracker 中分别计数
+	for i := 0; i < 2; i++ {
+		if _, err := ips.Failure(ctx, "same", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := accounts.Failure(ctx, "same", now); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := accounts.Check(ctx, "same", now); wait != 0 {
+		t.Fatalf("account locked by IP failures: %v", wait)
+	}
+	if wait, _ := ips.Check(ctx, "same", now); wait != time.Minute {
+		t.Fatalf("ip Check = %v, want 1m", wait)
+	}
+
+	// Cleanup 只删除自己前缀下已解锁且超过 ResetAfter 的记录
+	later := now.Add(testConfig.ResetAfter + time.Minute)
+	if err := accounts.Cleanup(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attempts.Get(ctx, "account:same"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expired account record after Cleanup: %v", err)
+	}
+	if _, err := attempts.Get(ctx, "ip:same"); err != nil {
+		t.Fatalf("ip record removed by the account Cleanup: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package logging 基于 log/slog 的结构化日志。
+//
+// 各子系统通过 For 取得 Logger，级别可以按子系统单独配置；日志带有 ctx 中的请求 ID 和追踪 ID，
+// 密码、Token、DSN 等敏感内容在输出前自动隐去（见 Redact）。
+// Init 之前输出的日志使用默认设置（JSON、info 级别、标准错误输出）。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 子系统名称
+const (
+	App       = "app"       // 启动、关闭
+	HTTP      = "http"      // 访问日志和接口处理
+	Auth      = "auth"      // 登录、第三方登录、审计
+	DB        = "db"        // 数据库连接和 SQL
+	Migrate   = "migrate"   // 数据库迁移
+	WS        = "ws"        // WebSocket/SSE 连接
+	Backplane = "backplane" // 多实例事件同步
+)
+
+// Subsystems 可以单独设置日志级别的子系统
+var Subsystems = []string{App, HTTP, Auth, DB, Migrate, WS, Backplane}
+
+// base 当前的输出 Handler，gen 在 Init 时递增，各子系统据此重建缓存的 Handler
+type base struct {
+	gen     uint64
+	handler slog.Handler
+}
+
+var (
+	current      atomic.Pointer[base]
+	defaultLevel slog.LevelVar
+
+	levelsMu sync.Mutex
+	levels   = map[string]*slog.LevelVar{}
+)
+
+func init() {
+	current.Store(&base{handler: newHandler(os.Stderr, "json")})
+}
+
+// Init 设置日志格式（json 或 text）、默认级别和各子系统的级别，并把标准库 log 的输出转到 App 子系统
+func Init(w io.Writer, format string, level slog.Level, subsystems map[string]slog.Level) {
+	levelsMu.Lock()
+	defaultLevel.Set(level)
+	for name, v := range levels {
+		if l, ok := subsystems[name]; ok {
+			v.Set(l)
+		} else {
+			v.Set(level)
+		}
+	}
+	for name, l := range subsystems {
+		if _, ok := levels[name]; !ok {
+			levels[name] = new(slog.LevelVar)
+			levels[name].Set(l)
+		}
+	}
+	levelsMu.Unlock()
+
+	current.Store(&base{gen: current.Load().gen + 1, handler: newHandler(w, format)})
+	slog.SetDefault(For(App))
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceAttr}
+	if format == "text" {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// For 返回子系统的 Logger，可以在 Init 之前创建（例如包级变量），Init 之后自动使用新的设置
+func For(subsystem string) *slog.Logger {
+	levelsMu.Lock()
+	level, ok := levels[subsystem]
+	if !ok {
+		level = new(slog.LevelVar)
+		level.Set(defaultLevel.Level())
+		levels[subsystem] = level
+	}
+	levelsMu.Unlock()
+
+	return slog.New(&handler{subsystem: subsystem, level: level, cache: new(atomic.Pointer[base])})
+}
+
+// Fatal 记录错误后退出进程
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// StdLogger 返回写入子系统 Logger 的标准库 *log.Logger，供只接受 *log.Logger 的库使用
+func StdLogger(subsystem string, level slog.Level) *log.Logger {
+	return slog.NewLogLogger(For(subsystem).Handler(), level)
+}
+
+// handler 按子系统的级别过滤，补充请求 ID 和追踪 ID 后交给当前的输出 Handler
+type handler struct {
+	subsystem string
+	level     *slog.LevelVar
+	// ops 记录 WithAttrs/WithGroup，Init 替换输出 Handler 后重新应用
+	ops   []func(slog.Handler) slog.Handler
+	cache *atomic.Pointer[base]
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.next().Handle(ctx, r)
+}
+
+func (h *handler) next() slog.Handler {
+	b := current.Load()
+	if cached := h.cache.Load(); cached != nil && cached.gen == b.gen {
+		return cached.handler
+	}
+
+	next := b.handler.WithAttrs([]slog.Attr{slog.String("subsystem", h.subsystem)})
+	for _, op := range h.ops {
+		next = op(next)
+	}
+	h.cache.Store(&base{gen: b.gen, handler: next})
+	return next
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{subsystem: h.subsystem, level: h.level, ops: append(ops, op), cache: new(atomic.Pointer[base])}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+// ParseLevel 解析 debug、info、warn、error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 在 ctx 中记录请求 ID，之后使用该 ctx 输出的日志都带有 request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时为空
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
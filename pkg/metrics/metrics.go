@@ -0,0 +1,123 @@
+// Package metrics 以 Prometheus 文本格式导出服务指标。
+//
+// 指标注册在独立的 Registry 中（而不是 prometheus.DefaultRegisterer），由 Handler 输出；
+// HTTP 请求、认证事件和任务变更由对应的代码直接计数，WebSocket 和数据库连接池在采集时读取。
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"todo-backend/pkg/ws"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "todo"
+
+// Registry 所有指标的注册表，包含 Go 运行时和进程指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests 按路由（路由定义而不是实际路径）、方法和状态码统计的请求数
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration 请求耗时，WebSocket 和 SSE 为整个连接的时长
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// AuthEvents 登录成功、失败、锁定以及两步验证结果，event 与审计记录的事件类型相同
+	AuthEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_events_total",
+		Help:      "Authentication events such as login_succeeded and login_failed.",
+	}, []string{"event"})
+
+	// TodosCreated 新建的任务数（通过 LocalID 命中已有任务的不计入）
+	TodosCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "todos_created_total",
+		Help:      "Todos created.",
+	})
+
+	// TodosCompleted 标记为完成的任务数
+	TodosCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "todos_completed_total",
+		Help:      "Todos marked as completed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, AuthEvents, TodosCreated, TodosCompleted,
+	)
+}
+
+// Handler 输出 Registry 中的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB 导出数据库连接池的统计（go_sql_* 指标）
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// RegisterWebSocket 导出 WebSocket/SSE 连接数、消息数和发送队列的占用情况；sendBuffer 为每个连接的发送队列长度
+func RegisterWebSocket(m *ws.WSManager, sendBuffer int) {
+	Registry.MustRegister(&wsCollector{manager: m, sendBuffer: sendBuffer})
+}
+
+var (
+	wsConnections  = prometheus.NewDesc(namespace+"_ws_connections", "Open WebSocket and SSE connections.", nil, nil)
+	wsUsers        = prometheus.NewDesc(namespace+"_ws_users", "Users with at least one open connection.", nil, nil)
+	wsSent         = prometheus.NewDesc(namespace+"_ws_messages_sent_total", "Messages written to clients.", nil, nil)
+	wsDropped      = prometheus.NewDesc(namespace+"_ws_messages_dropped_total", "Messages dropped by the drop_oldest slow consumer policy.", nil, nil)
+	wsDisconnected = prometheus.NewDesc(namespace+"_ws_slow_consumer_disconnects_total", "Clients disconnected because their send queue was full.", nil, nil)
+	wsBackplane    = prometheus.NewDesc(namespace+"_ws_backplane_dropped_total", "Events not forwarded to other instances because the backplane publish queue was full.", nil, nil)
+	wsQueued       = prometheus.NewDesc(namespace+"_ws_send_queue_messages", "Messages waiting in client send queues.", nil, nil)
+	wsSaturation   = prometheus.NewDesc(namespace+"_ws_send_buffer_saturation_ratio", "Fill ratio of the fullest client send queue relative to the send buffer.", nil, nil)
+)
+
+// wsCollector 采集时读取 ws.WSManager.Stats
+type wsCollector struct {
+	manager    *ws.WSManager
+	sendBuffer int
+}
+
+func (c *wsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{wsConnections, wsUsers, wsSent, wsDropped, wsDisconnected, wsBackplane, wsQueued, wsSaturation} {
+		ch <- desc
+	}
+}
+
+func (c *wsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.manager.Stats()
+
+	saturation := 0.0
+	if c.sendBuffer > 0 {
+		saturation = float64(stats.MaxQueued) / float64(c.sendBuffer)
+	}
+
+	ch <- prometheus.MustNewConstMetric(wsConnections, prometheus.GaugeValue, float64(stats.Connections))
+	ch <- prometheus.MustNewConstMetric(wsUsers, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(wsSent, prometheus.CounterValue, float64(stats.Sent))
+	ch <- prometheus.MustNewConstMetric(wsDropped, prometheus.CounterValue, float64(stats.Dropped))
+	ch <- prometheus.MustNewConstMetric(wsDisconnected, prometheus.CounterValue, float64(stats.Disconnected))
+	ch <- prometheus.MustNewConstMetric(wsBackplane, prometheus.CounterValue, float64(stats.BackplaneDropped))
+	ch <- prometheus.MustNewConstMetric(wsQueued, prometheus.GaugeValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(wsSaturation, prometheus.GaugeValue, saturation)
+}
@@ -0,0 +1,315 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/storage/sqlite"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		versions []int64
+		err      string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0010_add_tags.up.sql":  file("CREATE TABLE tags (id integer)"),
+				"0002_todos.up.sql":     file("CREATE TABLE todos (id integer)"),
+				"0002_todos.down.sql":   file("DROP TABLE todos"),
+				"README.md":             file("不是迁移文件"),
+				"archive/0001_x.up.sql": file("目录被忽略"),
+			},
+			versions: []int64{2, 10},
+		},
+		{name: "empty", fsys: fstest.MapFS{}, versions: []int64{}},
+		{name: "missing version", fsys: fstest.MapFS{"todos.up.sql": file("SELECT 1")}, err: "file name must be"},
+		{name: "missing direction", fsys: fstest.MapFS{"0001_todos.sql": file("SELECT 1")}, err: "file name must be"},
+		{name: "upper case name", fsys: fstest.MapFS{"0001_AddTodos.up.sql": file("SELECT 1")}, err: "file name must be"},
+		{name: "dash in name", fsys: fstest.MapFS{"0001_add-todos.up.sql": file("SELECT 1")}, err: "file name must be"},
+		{
+			name: "version used twice",
+			fsys: fstest.MapFS{
+				"0001_todos.up.sql": file("SELECT 1"),
+				"0001_tags.up.sql":  file("SELECT 1"),
+			},
+			err: "migration version 1 is used by both",
+		},
+		{name: "down only", fsys: fstest.MapFS{"0001_todos.down.sql": file("DROP TABLE todos")}, err: "0001_todos has no up script"},
+		{name: "blank up", fsys: fstest.MapFS{"0001_todos.up.sql": file("  \n")}, err: "0001_todos has no up script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := migrate.Load(tt.fsys)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Load error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions := make([]int64, len(loaded))
+			for i, m := range loaded {
+				versions[i] = m.Version
+			}
+			if len(versions) != len(tt.versions) {
+				t.Fatalf("versions = %v, want %v", versions, tt.versions)
+			}
+			for i := range versions {
+				if versions[i] != tt.versions[i] {
+					t.Fatalf("versions = %v, want %v", versions, tt.versions)
+				}
+			}
+		})
+	}
+
+	loaded, _ := migrate.Load(tests[0].fsys)
+	if loaded[0].Name != "todos" || loaded[0].Down != "DROP TABLE todos" || loaded[1].Down != "" {
+		t.Errorf("loaded = %+v", loaded)
+	}
+}
+
+// openSQLite 打开临时的 SQLite 数据库
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "todo.db"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+func newMigrator(t *testing.T, sqlDB *sql.DB, fsys fstest.MapFS) *migrate.Migrator {
+	t.Helper()
+	m, err := migrate.New(sqlDB, sqlite.Driver, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func tableExists(t *testing.T, sqlDB *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := sqlDB.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func names(migrations []migrate.Migration) string {
+	s := make([]string, len(migrations))
+	for i, m := range migrations {
+		s[i] = m.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// applied 把 Status 转成 "版本:是否已执行" 的列表，便于比较
+func applied(t *testing.T, m *migrate.Migrator) string {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := make([]string, len(statuses))
+	for i, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied"
+		}
+		if status.Unknown {
+			state = "unknown"
+		}
+		s[i] = status.Name + ":" + state
+	}
+	return strings.Join(s, ",")
+}
+
+var schema = fstest.MapFS{
+	"0001_todos.up.sql":        file("CREATE TABLE todos (id integer PRIMARY KEY, title text NOT NULL)"),
+	"0001_todos.down.sql":      file("DROP TABLE todos"),
+	"0002_tags.up.sql":         file("CREATE TABLE tags (id integer PRIMARY KEY); INSERT INTO tags (id) VALUES (1)"),
+	"0002_tags.down.sql":       file("DROP TABLE tags"),
+	"0003_todo_title.up.sql":   file("-- migrate:no-transaction\nCREATE INDEX todos_title ON todos (title)"),
+	"0003_todo_title.down.sql": file("-- migrate:no-transaction\nDROP INDEX todos_title"),
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openSQLite(t)
+	m := newMigrator(t, sqlDB, schema)
+
+	// schema_migrations 还不存在时全部为未执行
+	if got := applied(t, m); got != "todos:pending,tags:pending,todo_title:pending" {
+		t.Fatalf("status before Up = %s", got)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(done); got != "0001_todos,0002_tags,0003_todo_title" {
+		t.Fatalf("Up applied %s", got)
+	}
+	if got := applied(t, m); got != "todos:applied,tags:applied,todo_title:applied" {
+		t.Fatalf("status after Up = %s", got)
+	}
+
+	// 再次执行没有待执行的迁移
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %s, %v", names(done), err)
+	}
+
+	// 从最新的版本开始回滚
+	done, err = m.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(done); got != "0003_todo_title,0002_tags" {
+		t.Fatalf("Down(2) rolled back %s", got)
+	}
+	if got := applied(t, m); got != "todos:applied,tags:pending,todo_title:pending" {
+		t.Fatalf("status after Down = %s", got)
+	}
+	if tableExists(t, sqlDB, "tags") || !tableExists(t, sqlDB, "todos") {
+		t.Fatal("Down(2) did not drop tags only")
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || names(pending) != "0002_tags,0003_todo_title" {
+		t.Fatalf("Pending = %s, %v", names(pending), err)
+	}
+
+	// steps 超过已执行的数量时回滚全部
+	done, err = m.Down(ctx, 10)
+	if err != nil || names(done) != "0001_todos" {
+		t.Fatalf("Down(10) = %s, %v", names(done), err)
+	}
+	if tableExists(t, sqlDB, "todos") {
+		t.Fatal("todos still exists after rolling back everything")
+	}
+
+	if done, err := m.Up(ctx); err != nil || len(done) != 3 {
+		t.Fatalf("Up after Down = %s, %v", names(done), err)
+	}
+}
+
+func TestUpFailureRollsBackMigration(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openSQLite(t)
+	m := newMigrator(t, sqlDB, fstest.MapFS{
+		"0001_todos.up.sql": file("CREATE TABLE todos (id integer PRIMARY KEY)"),
+		"0002_tags.up.sql":  file("CREATE TABLE tags (id integer PRIMARY KEY); INSERT INTO missing (id) VALUES (1)"),
+		"0003_later.up.sql": file("CREATE TABLE later (id integer PRIMARY KEY)"),
+	})
+
+	done, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "migration 0002_tags") {
+		t.Fatalf("Up error = %v", err)
+	}
+	if got := names(done); got != "0001_todos" {
+		t.Fatalf("Up applied %s before failing", got)
+	}
+
+	// 失败的迁移整体回滚，后面的迁移不执行
+	if tableExists(t, sqlDB, "tags") || tableExists(t, sqlDB, "later") {
+		t.Fatal("failed migration left tables behind")
+	}
+	if got := applied(t, m); got != "todos:applied,tags:pending,later:pending" {
+		t.Fatalf("status after failure = %s", got)
+	}
+}
+
+func TestDownErrors(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openSQLite(t)
+	if _, err := newMigrator(t, sqlDB, schema).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库已被包含更多迁移的版本更新过
+	older := newMigrator(t, sqlDB, fstest.MapFS{
+		"0001_todos.up.sql":   schema["0001_todos.up.sql"],
+		"0001_todos.down.sql": schema["0001_todos.down.sql"],
+	})
+	if got := applied(t, older); got != "todos:applied,tags:unknown,todo_title:unknown" {
+		t.Fatalf("status with unknown versions = %s", got)
+	}
+	if _, err := older.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "version 3 (todo_title) is applied but has no migration file") {
+		t.Fatalf("Down with unknown version error = %v", err)
+	}
+
+	noDown := newMigrator(t, sqlDB, fstest.MapFS{
+		"0001_todos.up.sql":      schema["0001_todos.up.sql"],
+		"0002_tags.up.sql":       schema["0002_tags.up.sql"],
+		"0003_todo_title.up.sql": schema["0003_todo_title.up.sql"],
+	})
+	if _, err := noDown.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "0003_todo_title cannot be rolled back") {
+		t.Fatalf("Down without down script error = %v", err)
+	}
+	if got := applied(t, noDown); got != "todos:applied,tags:applied,todo_title:applied" {
+		t.Fatalf("failed Down changed status: %s", got)
+	}
+}
+
+func TestNewUnsupportedDriver(t *testing.T) {
+	if _, err := migrate.New(nil, "mysql", schema); err == nil || !strings.Contains(err.Error(), `driver "mysql"`) {
+		t.Fatalf("New error = %v", err)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openSQLite(t)
+	fsys, err := migrations.For(sqlite.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(sqlDB, sqlite.Driver, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 随程序发布的迁移可以完整执行、回滚并重新执行
+	up, err := m.Up(ctx)
+	if err != nil || len(up) == 0 {
+		t.Fatalf("Up = %s, %v", names(up), err)
+	}
+	down, err := m.Down(ctx, len(up))
+	if err != nil || len(down) != len(up) {
+		t.Fatalf("Down = %s, %v", names(down), err)
+	}
+	var tables int
+	if err := sqlDB.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatalf("%d tables left after rolling back every migration", tables)
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != len(up) {
+		t.Fatalf("Up after Down = %s, %v", names(again), err)
+	}
+}
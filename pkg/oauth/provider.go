@@ -0,0 +1,276 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email address is not verified by provider")
+	ErrMissingIDToken   = errors.New("token response does not contain id_token")
+)
+
+// ProviderConfig 单个登录提供方的配置。
+// 标准 OIDC 提供方只需配置 Issuer（或 DiscoveryURL），端点通过发现文档获取；
+// GitHub 这类纯 OAuth2 提供方需要显式配置 AuthURL、TokenURL 和 UserInfoURL。
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	EmailsURL   string // GitHub 的 /user/emails，用于获取已验证的主邮箱
+}
+
+// IsOIDC 是否通过 OIDC 发现文档和 ID Token 完成登录
+func (c ProviderConfig) IsOIDC() bool {
+	return c.Issuer != "" || c.DiscoveryURL != ""
+}
+
+// Identity 提供方返回的用户身份
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider 封装授权码 + PKCE 流程
+type Provider struct {
+	Config ProviderConfig
+
+	mutex    sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	userInfo string
+}
+
+func NewProvider(cfg ProviderConfig) *Provider {
+	return &Provider{Config: cfg}
+}
+
+// setup 延迟加载发现文档，失败时下次请求会重试，避免提供方短暂不可用导致服务无法启动
+func (p *Provider) setup(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.oauth2 != nil {
+		return nil
+	}
+
+	cfg := &oauth2.Config{
+		ClientID:     p.Config.ClientID,
+		ClientSecret: p.Config.ClientSecret,
+		RedirectURL:  p.Config.RedirectURL,
+		Scopes:       p.Config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.Config.AuthURL,
+			TokenURL: p.Config.TokenURL,
+		},
+	}
+
+	if !p.Config.IsOIDC() {
+		p.userInfo = p.Config.UserInfoURL
+		p.oauth2 = cfg
+		return nil
+	}
+
+	discovery, err := discover(ctx, p.Config)
+	if err != nil {
+		return err
+	}
+
+	provider := discovery.NewProvider(ctx)
+	cfg.Endpoint = provider.Endpoint()
+	if p.Config.AuthURL != "" {
+		cfg.Endpoint.AuthURL = p.Config.AuthURL
+	}
+	if p.Config.TokenURL != "" {
+		cfg.Endpoint.TokenURL = p.Config.TokenURL
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.Config.ClientID})
+	p.userInfo = discovery.UserInfoURL
+	p.oauth2 = cfg
+	return nil
+}
+
+// discover 读取发现文档，并校验其中的 issuer 与配置一致
+func discover(ctx context.Context, cfg ProviderConfig) (*oidc.ProviderConfig, error) {
+	discoveryURL := cfg.DiscoveryURL
+	if discoveryURL == "" {
+		discoveryURL = strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch discovery document: unexpected status %s", resp.Status)
+	}
+
+	var doc oidc.ProviderConfig
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode discovery document: %w", err)
+	}
+
+	if cfg.Issuer != "" && strings.TrimSuffix(doc.IssuerURL, "/") != strings.TrimSuffix(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("discovery issuer %q does not match configured issuer %q", doc.IssuerURL, cfg.Issuer)
+	}
+
+	return &doc, nil
+}
+
+// AuthCodeURL 生成跳转到提供方的授权地址（带 PKCE S256 challenge 和 nonce）
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if err := p.setup(ctx); err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	if p.verifier != nil {
+		opts = append(opts, oidc.Nonce(nonce))
+	}
+	return p.oauth2.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange 用授权码换取 Token，并解析出用户身份
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	if err := p.setup(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, httpClient(ctx))
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange authorization code: %w", err)
+	}
+
+	if p.verifier != nil {
+		return p.identityFromIDToken(ctx, token, nonce)
+	}
+	return p.identityFromUserInfo(ctx, token)
+}
+
+func (p *Provider) identityFromIDToken(ctx context.Context, token *oauth2.Token, nonce string) (*Identity, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode id_token claims: %w", err)
+	}
+
+	return &Identity{
+		Provider:      p.Config.Name,
+		Subject:       idToken.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// identityFromUserInfo 纯 OAuth2 提供方通过用户信息接口获取身份（GitHub 格式）
+func (p *Provider) identityFromUserInfo(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	client := p.oauth2.Client(ctx, token)
+
+	var profile struct {
+		ID    json.Number `json:"id"`
+		Login string      `json:"login"`
+		Name  string      `json:"name"`
+	}
+	if err := getJSON(client, p.userInfo, &profile); err != nil {
+		return nil, fmt.Errorf("fetch user info: %w", err)
+	}
+
+	identity := &Identity{
+		Provider: p.Config.Name,
+		Subject:  profile.ID.String(),
+		Name:     profile.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+
+	if p.Config.EmailsURL != "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := getJSON(client, p.Config.EmailsURL, &emails); err != nil {
+			return nil, fmt.Errorf("fetch user emails: %w", err)
+		}
+		for _, e := range emails {
+			if e.Primary {
+				identity.Email = strings.ToLower(e.Email)
+				identity.EmailVerified = e.Verified
+				break
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// httpClient 允许调用方（例如连接本地模拟提供方时）通过 oauth2.HTTPClient 注入自定义客户端
+func httpClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"sort"
+	"strings"
+)
+
+// Providers 已配置的登录提供方，按名称索引
+var Providers = map[string]*Provider{}
+
+// presets 常用提供方的默认配置，配置中设置了的字段会覆盖这些值
+var presets = map[string]ProviderConfig{
+	"google": {
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"github": {
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+}
+
+// InitProviders 注册已启用的提供方（配置来自 config.OAuthConfig），google、github 未设置的端点和 Scopes
+// 使用内置默认值；未设置 RedirectURL 时使用 callbackBaseURL + /api/oauth/<name>/callback
+func InitProviders(configs []ProviderConfig, callbackBaseURL string) {
+	for _, cfg := range configs {
+		Register(NewProvider(withDefaults(cfg, callbackBaseURL)))
+	}
+}
+
+// Register 注册一个提供方，同名会被覆盖
+func Register(p *Provider) {
+	Providers[p.Config.Name] = p
+}
+
+// Names 返回已注册的提供方名称（按字母排序）
+func Names() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func withDefaults(cfg ProviderConfig, callbackBaseURL string) ProviderConfig {
+	cfg.Name = strings.ToLower(cfg.Name)
+	preset := presets[cfg.Name]
+
+	fill := func(target *string, value string) {
+		if *target == "" {
+			*target = value
+		}
+	}
+	fill(&cfg.Issuer, preset.Issuer)
+	fill(&cfg.DiscoveryURL, preset.DiscoveryURL)
+	fill(&cfg.AuthURL, preset.AuthURL)
+	fill(&cfg.TokenURL, preset.TokenURL)
+	fill(&cfg.UserInfoURL, preset.UserInfoURL)
+	fill(&cfg.EmailsURL, preset.EmailsURL)
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = preset.Scopes
+	}
+
+	if cfg.RedirectURL == "" {
+		base := strings.TrimSuffix(callbackBaseURL, "/")
+		cfg.RedirectURL = base + "/api/oauth/" + cfg.Name + "/callback"
+	}
+	return cfg
+}
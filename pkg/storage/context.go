@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnavailable 数据库暂时不可用（连接失败或连接池已关闭），可以稍后重试
+var ErrUnavailable = errors.New("database unavailable")
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout 设置 ctx 上每条数据库语句的超时时间，覆盖默认值；d <= 0 表示不限制
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, d)
+}
+
+// StatementTimeout 返回 ctx 上设置的语句超时时间，未设置时 ok 为 false
+func StatementTimeout(ctx context.Context) (d time.Duration, ok bool) {
+	d, ok = ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return d, ok
+}
@@ -0,0 +1,17 @@
+package gormstore
+
+import (
+	"context"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type authEventStore struct {
+	db *gorm.DB
+}
+
+func (s authEventStore) Create(ctx context.Context, event *model.AuthEvent) error {
+	return s.db.WithContext(ctx).Create(event).Error
+}
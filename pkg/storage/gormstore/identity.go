@@ -0,0 +1,74 @@
+package gormstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type identityStore struct {
+	db *gorm.DB
+}
+
+func (s identityStore) GetUser(ctx context.Context, provider, subject string) (*model.User, error) {
+	var user model.User
+	err := s.db.WithContext(ctx).
+		Joins("JOIN identities ON identities.user_id = users.id").
+		Where("identities.provider = ? AND identities.subject = ?", provider, subject).
+		First(&user).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (s identityStore) Link(ctx context.Context, user *model.User, identity *model.Identity) error {
+	return translate(s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing model.User
+		err := tx.Where("LOWER(email) = ?", strings.ToLower(user.Email)).First(&existing).Error
+		switch {
+		case err == nil:
+			*user = existing
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	}))
+}
+
+func (s identityStore) List(ctx context.Context, userID uint) ([]model.Identity, error) {
+	var identities []model.Identity
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (s identityStore) Get(ctx context.Context, userID, id uint) (*model.Identity, error) {
+	var identity model.Identity
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (s identityStore) Delete(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&model.Identity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return translate(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type loginAttemptStore struct {
+	db *gorm.DB
+}
+
+func (s loginAttemptStore) Get(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	if err := s.db.WithContext(ctx).Where("subject = ?", subject).First(&attempt).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &attempt, nil
+}
+
+func (s loginAttemptStore) Fail(ctx context.Context, subject string, now, resetBefore time.Time) (int, error) {
+	var attempt model.LoginAttempt
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 并发的失败由数据库按行串行执行，计数不会丢失
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "subject"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":     gorm.Expr("CASE WHEN login_attempts.last_failure < ? THEN 1 ELSE login_attempts.failures + 1 END", utc(resetBefore)),
+				"last_failure": utc(now),
+			}),
+		}).Create(&model.LoginAttempt{Subject: subject, Failures: 1, LastFailure: utc(now)}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("subject = ?", subject).First(&attempt).Error
+	})
+	if err != nil {
+		return 0, translate(err)
+	}
+	return attempt.Failures, nil
+}
+
+func (s loginAttemptStore) Lock(ctx context.Context, subject string, until time.Time) error {
+	until = utc(until)
+	return s.db.WithContext(ctx).Model(&model.LoginAttempt{}).
+		Where("subject = ? AND (locked_until IS NULL OR locked_until < ?)", subject, until).
+		Update("locked_until", until).Error
+}
+
+func (s loginAttemptStore) Delete(ctx context.Context, subject string) error {
+	return s.db.WithContext(ctx).Where("subject = ?", subject).Delete(&model.LoginAttempt{}).Error
+}
+
+func (s loginAttemptStore) DeleteExpired(ctx context.Context, prefix string, now, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("subject LIKE ? AND (locked_until IS NULL OR locked_until < ?) AND last_failure < ?", prefix+"%", utc(now), utc(before)).
+		Delete(&model.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,95 @@
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type sessionStore struct {
+	db *gorm.DB
+}
+
+func (s sessionStore) Create(ctx context.Context, session *model.Session) error {
+	session.LastSeenAt = utc(session.LastSeenAt)
+	session.ExpiresAt = utc(session.ExpiresAt)
+	return translate(s.db.WithContext(ctx).Create(session).Error)
+}
+
+func (s sessionStore) Get(ctx context.Context, id uint) (*model.Session, error) {
+	var session model.Session
+	if err := s.db.WithContext(ctx).First(&session, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &session, nil
+}
+
+func (s sessionStore) ListByIDs(ctx context.Context, ids []uint) ([]model.Session, error) {
+	var sessions []model.Session
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s sessionStore) ListActive(ctx context.Context, userID uint, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, utc(now)).
+		Order("last_seen_at DESC").Order("id DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s sessionStore) Touch(ctx context.Context, id uint, now time.Time) error {
+	return s.db.WithContext(ctx).Model(&model.Session{ID: id}).UpdateColumn("last_seen_at", utc(now)).Error
+}
+
+func (s sessionStore) Revoke(ctx context.Context, userID, id uint, now time.Time) (*model.Session, error) {
+	var session model.Session
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&session).Error; err != nil {
+			return err
+		}
+		revokedAt := utc(now)
+		session.RevokedAt = &revokedAt
+		return tx.Model(&session).Update("revoked_at", revokedAt).Error
+	})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &session, nil
+}
+
+func (s sessionStore) RevokeOthers(ctx context.Context, userID, keepID uint, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+			Order("id").Find(&sessions).Error; err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(sessions))
+		revokedAt := utc(now)
+		for i := range sessions {
+			ids[i] = sessions[i].ID
+			sessions[i].RevokedAt = &revokedAt
+		}
+		return tx.Model(&model.Session{}).Where("id IN ?", ids).Update("revoked_at", revokedAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,60 @@
+// Package gormstore 基于 GORM 的 storage.Store 实现，PostgreSQL 和 SQLite 共用。
+//
+// 打开数据库时需要设置 gorm.Config.TranslateError，唯一约束冲突才能映射为 storage.ErrDuplicate。
+// 写入和比较的时间统一使用 UTC 并取整到微秒：SQLite 以文本保存时间，只有时区一致时按字符串比较才正确，
+// 微秒则是 PostgreSQL 的精度，两种数据库读回的时间因此相同。
+package gormstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-backend/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+type store struct {
+	db *gorm.DB
+}
+
+// New 用已连接并完成迁移的数据库创建 Store
+func New(db *gorm.DB) storage.Store {
+	return &store{db: db}
+}
+
+func (s *store) Todos() storage.TodoStore                 { return todoStore{s.db} }
+func (s *store) Users() storage.UserStore                 { return userStore{s.db} }
+func (s *store) Sessions() storage.SessionStore           { return sessionStore{s.db} }
+func (s *store) Tokens() storage.TokenStore               { return tokenStore{s.db} }
+func (s *store) Identities() storage.IdentityStore        { return identityStore{s.db} }
+func (s *store) AuthEvents() storage.AuthEventStore       { return authEventStore{s.db} }
+func (s *store) LoginAttempts() storage.LoginAttemptStore { return loginAttemptStore{s.db} }
+
+func (s *store) SQL() (*sql.DB, error) {
+	return s.db.DB()
+}
+
+func (s *store) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// translate 把 GORM 的错误映射为 storage 包的错误
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return storage.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return storage.ErrDuplicate
+	}
+	return err
+}
+
+func utc(t time.Time) time.Time {
+	return t.UTC().Round(time.Microsecond)
+}
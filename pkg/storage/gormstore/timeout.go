@@ -0,0 +1,96 @@
+package gormstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"todo-backend/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+const statementContextKey = "todo:statement_context"
+
+// statementContext 语句执行前的 ctx 和超时的取消函数
+type statementContext struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// RegisterTimeouts 给每条语句加上超时：ctx 上通过 storage.WithStatementTimeout 设置的值优先，否则使用 defaultTimeout（<= 0 不限制）。
+// 超时或请求取消时返回的错误满足 errors.Is(err, context.DeadlineExceeded / context.Canceled)，
+// 连接失败包装为 storage.ErrUnavailable。
+// Row/Rows 由调用方读取结果，不在这里设置超时
+func RegisterTimeouts(db *gorm.DB, defaultTimeout time.Duration) error {
+	begin := func(db *gorm.DB) { beginStatement(db, defaultTimeout) }
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("todo:begin_statement", begin),
+		cb.Create().After("*").Register("todo:end_statement", endStatement),
+		cb.Query().Before("*").Register("todo:begin_statement", begin),
+		cb.Query().After("*").Register("todo:end_statement", endStatement),
+		cb.Update().Before("*").Register("todo:begin_statement", begin),
+		cb.Update().After("*").Register("todo:end_statement", endStatement),
+		cb.Delete().Before("*").Register("todo:begin_statement", begin),
+		cb.Delete().After("*").Register("todo:end_statement", endStatement),
+		cb.Raw().Before("*").Register("todo:begin_statement", begin),
+		cb.Raw().After("*").Register("todo:end_statement", endStatement),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func beginStatement(db *gorm.DB, defaultTimeout time.Duration) {
+	parent := db.Statement.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	timeout, ok := storage.StatementTimeout(parent)
+	if !ok {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := parent, context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	}
+	db.Statement.Context = ctx
+	db.InstanceSet(statementContextKey, statementContext{parent: parent, cancel: cancel})
+}
+
+func endStatement(db *gorm.DB) {
+	v, ok := db.InstanceGet(statementContextKey)
+	if !ok {
+		return
+	}
+	sc := v.(statementContext)
+
+	if db.Error != nil {
+		if ctxErr := db.Statement.Context.Err(); ctxErr != nil {
+			// 驱动返回的错误不一定包装了 ctx 的错误（例如 SQLite 只返回 interrupted）
+			if !errors.Is(db.Error, ctxErr) {
+				db.Error = fmt.Errorf("%w: %w", ctxErr, db.Error)
+			}
+		} else if unavailable(db.Error) {
+			db.Error = fmt.Errorf("%w: %w", storage.ErrUnavailable, db.Error)
+		}
+	}
+
+	sc.cancel()
+	db.Statement.Context = sc.parent
+}
+
+// unavailable 判断错误是否为连接层面的失败
+func unavailable(err error) bool {
+	var netErr *net.OpError
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
@@ -0,0 +1,66 @@
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type todoStore struct {
+	db *gorm.DB
+}
+
+func (s todoStore) Create(ctx context.Context, todo *model.Todo) error {
+	return translate(s.db.WithContext(ctx).Create(todo).Error)
+}
+
+func (s todoStore) Get(ctx context.Context, userID, id uint) (*model.Todo, error) {
+	var todo model.Todo
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&todo).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &todo, nil
+}
+
+func (s todoStore) GetByLocalID(ctx context.Context, userID uint, localID string) (*model.Todo, error) {
+	var todo model.Todo
+	if err := s.db.WithContext(ctx).Where("local_id = ? AND user_id = ?", localID, userID).First(&todo).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &todo, nil
+}
+
+func (s todoStore) List(ctx context.Context, userID uint) ([]model.Todo, error) {
+	var todos []model.Todo
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (s todoStore) ChangedSince(ctx context.Context, userID uint, since time.Time) ([]model.Todo, error) {
+	var todos []model.Todo
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND (updated_at > ? OR deleted_at > ?)", userID, utc(since), utc(since)).
+		Order("id").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (s todoStore) Update(ctx context.Context, todo *model.Todo) error {
+	return translate(s.db.WithContext(ctx).Save(todo).Error)
+}
+
+func (s todoStore) Delete(ctx context.Context, todo *model.Todo) error {
+	return translate(s.db.WithContext(ctx).Delete(todo).Error)
+}
+
+func (s todoStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Unscoped().Where("deleted_at < ?", utc(before)).Delete(&model.Todo{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,69 @@
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type tokenStore struct {
+	db *gorm.DB
+}
+
+func (s tokenStore) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	if token.ExpiresAt != nil {
+		expiresAt := utc(*token.ExpiresAt)
+		token.ExpiresAt = &expiresAt
+	}
+	return translate(s.db.WithContext(ctx).Create(token).Error)
+}
+
+func (s tokenStore) GetByHash(ctx context.Context, hash string) (*model.PersonalAccessToken, error) {
+	var token model.PersonalAccessToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ? AND revoked_at IS NULL", hash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (s tokenStore) List(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error) {
+	var tokens []model.PersonalAccessToken
+	if err := s.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s tokenStore) ListByIDs(ctx context.Context, ids []uint) ([]model.PersonalAccessToken, error) {
+	var tokens []model.PersonalAccessToken
+	if len(ids) == 0 {
+		return tokens, nil
+	}
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s tokenStore) Touch(ctx context.Context, id uint, now time.Time) error {
+	return s.db.WithContext(ctx).Model(&model.PersonalAccessToken{ID: id}).UpdateColumn("last_used_at", utc(now)).Error
+}
+
+func (s tokenStore) Revoke(ctx context.Context, userID, id uint, now time.Time) (*model.PersonalAccessToken, error) {
+	var token model.PersonalAccessToken
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&token).Error; err != nil {
+			return err
+		}
+		revokedAt := utc(now)
+		token.RevokedAt = &revokedAt
+		return tx.Model(&token).Update("revoked_at", revokedAt).Error
+	})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
@@ -0,0 +1,139 @@
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"todo-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type userStore struct {
+	db *gorm.DB
+}
+
+func (s userStore) Create(ctx context.Context, user *model.User) error {
+	return translate(s.db.WithContext(ctx).Create(user).Error)
+}
+
+func (s userStore) Get(ctx context.Context, id uint) (*model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (s userStore) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (s userStore) ListByIDs(ctx context.Context, ids []uint) ([]model.User, error) {
+	var users []model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s userStore) SetTOTPSecret(ctx context.Context, id uint, secret string) error {
+	return s.updateUser(s.db.WithContext(ctx), id, map[string]interface{}{"totp_secret": secret})
+}
+
+func (s userStore) EnableTwoFactor(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) (bool, error) {
+	return s.useStep(ctx, id, step, recoveryCodeHashes, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("totp_enabled = ?", false).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+	})
+}
+
+func (s userStore) DisableTwoFactor(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.updateUser(tx, id, map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}); err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&model.RecoveryCode{}).Error
+	})
+}
+
+func (s userStore) ReplaceRecoveryCodes(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) (bool, error) {
+	return s.useStep(ctx, id, step, recoveryCodeHashes, func(tx *gorm.DB) *gorm.DB {
+		return tx.Update("totp_last_step", step)
+	})
+}
+
+// useStep 在同一事务中用条件更新占用时间步并替换恢复码，并发请求中只有一个能用同一验证码成功
+func (s userStore) useStep(ctx context.Context, id uint, step int64, hashes []string, update func(tx *gorm.DB) *gorm.DB) (bool, error) {
+	used := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := update(tx.Model(&model.User{}).Where("id = ? AND totp_last_step < ?", id, step))
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		used = true
+		return replaceRecoveryCodes(tx, id, hashes)
+	})
+	return used && err == nil, err
+}
+
+func (s userStore) UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s userStore) UseRecoveryCode(ctx context.Context, id uint, codeHash string, now time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", id, codeHash).
+		Update("used_at", utc(now))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// updateUser 更新用户的部分字段，用户不存在时返回 storage.ErrNotFound
+func (s userStore) updateUser(tx *gorm.DB, id uint, fields map[string]interface{}) error {
+	result := tx.Model(&model.User{ID: id}).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return translate(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 删除用户现有的恢复码并写入新的一组
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, hashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	records := make([]model.RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		records[i] = model.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&records).Error
+}
@@ -0,0 +1,33 @@
+// Package postgres 打开 PostgreSQL 数据库，供 gormstore 使用
+package postgres
+
+import (
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Driver 配置中的驱动名
+const Driver = "postgres"
+
+// Open 连接 dsn（URL 或 key=value 形式）并检查连接可用
+func Open(dsn string, config *gorm.Config) (*gorm.DB, error) {
+	config.TranslateError = true
+	// 与 timestamptz 的精度一致，保证内存中的时间与读回的相同（同步游标依赖这一点）
+	config.NowFunc = func() time.Time { return time.Now().Round(time.Microsecond) }
+
+	db, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
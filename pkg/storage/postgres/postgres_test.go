@@ -0,0 +1,93 @@
+package postgres_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/storage/gormstore"
+	"todo-backend/pkg/storage/postgres"
+	"todo-backend/pkg/storage/storagetest"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dsnEnv 测试用 PostgreSQL 的连接串，例如 postgres://postgres@localhost/todo_test?sslmode=disable；
+// 每个子测试在其中新建一个 schema，结束后删除
+const dsnEnv = "TEST_POSTGRES_DSN"
+
+func TestStore(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnEnv)
+	}
+	admin, err := postgres.Open(dsn, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	adminDB, err := admin.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { adminDB.Close() })
+
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		schema := "storagetest_" + randomSuffix(t)
+		if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+		t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+		db, err := postgres.Open(withSearchPath(dsn, schema), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 在删除 schema 之前关闭（Cleanup 按注册的相反顺序执行）
+		t.Cleanup(func() { sqlDB.Close() })
+
+		fsys, err := migrations.For(postgres.Driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := migrate.New(sqlDB, postgres.Driver, fsys)
+		if err != nil {
+			t.Fatalf("load migrations: %v", err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return gormstore.New(db)
+	})
+}
+
+// withSearchPath 让连接默认使用 schema，dsn 可以是 URL 或 key=value 形式
+func withSearchPath(dsn, schema string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		if strings.Contains(dsn, "?") {
+			return dsn + "&search_path=" + schema
+		}
+		return dsn + "?search_path=" + schema
+	default:
+		return dsn + " search_path=" + schema
+	}
+}
+
+func randomSuffix(t *testing.T) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
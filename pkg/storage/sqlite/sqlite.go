@@ -0,0 +1,46 @@
+// Package sqlite 打开内嵌的 SQLite 数据库（纯 Go 实现，不需要 cgo），供 gormstore 使用。
+// 适合单实例部署，例如在树莓派上自托管，以及不依赖外部数据库的测试
+package sqlite
+
+import (
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// Driver 配置中的驱动名
+const Driver = "sqlite"
+
+// pragmas 每个连接打开时设置：启用外键约束；WAL 允许读写并发；写锁被占用时等待而不是立即失败。
+// 事务以 IMMEDIATE 开始，避免读事务升级为写事务时出现无法等待的 SQLITE_BUSY
+const pragmas = "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate&_time_format=sqlite"
+
+// Open 打开（不存在时创建）path 处的数据库文件
+func Open(path string, config *gorm.Config) (*gorm.DB, error) {
+	config.TranslateError = true
+	// SQLite 以文本保存时间，统一使用 UTC 才能按字符串比较；精度与 PostgreSQL 保持一致
+	config.NowFunc = func() time.Time { return time.Now().UTC().Round(time.Microsecond) }
+
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&" + pragmas
+	} else {
+		dsn += "?" + pragmas
+	}
+
+	db, err := gorm.Open(sqlite.Open(dsn), config)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,44 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"todo-backend/pkg/db/migrations"
+	"todo-backend/pkg/migrate"
+	"todo-backend/pkg/storage"
+	"todo-backend/pkg/storage/gormstore"
+	"todo-backend/pkg/storage/sqlite"
+	"todo-backend/pkg/storage/storagetest"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		db, err := sqlite.Open(filepath.Join(t.TempDir(), "todo.db"), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+
+		fsys, err := migrations.For(sqlite.Driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := migrate.New(sqlDB, sqlite.Driver, fsys)
+		if err != nil {
+			t.Fatalf("load migrations: %v", err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return gormstore.New(db)
+	})
+}
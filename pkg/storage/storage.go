@@ -0,0 +1,142 @@
+// Package storage 定义待办事项、用户、会话、Personal Access Token、第三方身份、认证审计记录和登录失败计数的存储接口。
+//
+// 实现位于 gormstore，底层数据库由 postgres 或 sqlite 包打开，按配置（DB_DRIVER）选择；
+// 所有实现都应通过 storagetest 中的一致性测试。
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-backend/internal/model"
+)
+
+var (
+	// ErrNotFound 记录不存在（或不属于该用户）
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate 违反唯一约束，例如邮箱已注册
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// Store 业务数据的存储
+type Store interface {
+	Todos() TodoStore
+	Users() UserStore
+	Sessions() SessionStore
+	Tokens() TokenStore
+	Identities() IdentityStore
+	AuthEvents() AuthEventStore
+	LoginAttempts() LoginAttemptStore
+	// SQL 底层的连接池，供迁移、健康检查和连接池指标使用
+	SQL() (*sql.DB, error)
+	Close() error
+}
+
+// TodoStore 待办事项。删除为软删除，已删除的任务只出现在 ChangedSince 的结果中
+type TodoStore interface {
+	Create(ctx context.Context, todo *model.Todo) error
+	// Get 按 ID 读取用户的任务
+	Get(ctx context.Context, userID, id uint) (*model.Todo, error)
+	// GetByLocalID 按客户端生成的 LocalID 读取任务，用于离线同步重放
+	GetByLocalID(ctx context.Context, userID uint, localID string) (*model.Todo, error)
+	List(ctx context.Context, userID uint) ([]model.Todo, error)
+	// ChangedSince 返回 since 之后更新或删除的任务，包括已删除的（DeletedAt 有效）
+	ChangedSince(ctx context.Context, userID uint, since time.Time) ([]model.Todo, error)
+	// Update 保存任务的全部字段并刷新 UpdatedAt
+	Update(ctx context.Context, todo *model.Todo) error
+	// Delete 软删除任务并设置 todo.DeletedAt
+	Delete(ctx context.Context, todo *model.Todo) error
+	// PurgeDeleted 彻底删除 before 之前软删除的任务，返回删除的数量
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// UserStore 用户及其两步验证数据（密钥、时间步、恢复码）
+type UserStore interface {
+	// Create 创建用户，邮箱已存在时返回 ErrDuplicate
+	Create(ctx context.Context, user *model.User) error
+	Get(ctx context.Context, id uint) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	// ListByIDs 读取一组用户，不存在的 ID 被忽略
+	ListByIDs(ctx context.Context, ids []uint) ([]model.User, error)
+
+	// SetTOTPSecret 保存待确认的两步验证密钥
+	SetTOTPSecret(ctx context.Context, id uint, secret string) error
+	// EnableTwoFactor 开启两步验证，记录时间步并替换全部恢复码（只保存哈希）。
+	// 已开启或 step 不大于上次记录的值时不做修改并返回 false
+	EnableTwoFactor(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) (bool, error)
+	// DisableTwoFactor 关闭两步验证，清除密钥和恢复码
+	DisableTwoFactor(ctx context.Context, id uint) error
+	// ReplaceRecoveryCodes 记录时间步并替换全部恢复码；step 不大于上次记录的值时不做修改并返回 false
+	ReplaceRecoveryCodes(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) (bool, error)
+	// UseTOTPStep 记录已使用的时间步；step 不大于上次记录的值（验证码重放）时返回 false
+	UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	// UseRecoveryCode 作废一个未使用的恢复码，不存在或已使用时返回 false
+	UseRecoveryCode(ctx context.Context, id uint, codeHash string, now time.Time) (bool, error)
+}
+
+// SessionStore 登录会话
+type SessionStore interface {
+	Create(ctx context.Context, session *model.Session) error
+	Get(ctx context.Context, id uint) (*model.Session, error)
+	// ListByIDs 读取一组会话（包括已撤销、已过期的），不存在的 ID 被忽略
+	ListByIDs(ctx context.Context, ids []uint) ([]model.Session, error)
+	// ListActive 返回用户未撤销、未过期的会话，最近活跃的在前
+	ListActive(ctx context.Context, userID uint, now time.Time) ([]model.Session, error)
+	// Touch 更新最近活跃时间
+	Touch(ctx context.Context, id uint, now time.Time) error
+	// Revoke 撤销用户的一个会话，会话不存在或已撤销时返回 ErrNotFound
+	Revoke(ctx context.Context, userID, id uint, now time.Time) (*model.Session, error)
+	// RevokeOthers 撤销用户除 keepID 以外所有未撤销的会话，返回被撤销的会话
+	RevokeOthers(ctx context.Context, userID, keepID uint, now time.Time) ([]model.Session, error)
+}
+
+// TokenStore Personal Access Token，只保存哈希
+type TokenStore interface {
+	Create(ctx context.Context, token *model.PersonalAccessToken) error
+	// GetByHash 按哈希读取未撤销的 Token（不检查是否过期），不存在或已撤销时返回 ErrNotFound
+	GetByHash(ctx context.Context, hash string) (*model.PersonalAccessToken, error)
+	// List 返回用户未撤销的 Token（包括已过期的），按 ID 排序
+	List(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error)
+	// ListByIDs 读取一组 Token（包括已撤销的），不存在的 ID 被忽略
+	ListByIDs(ctx context.Context, ids []uint) ([]model.PersonalAccessToken, error)
+	// Touch 更新最近使用时间
+	Touch(ctx context.Context, id uint, now time.Time) error
+	// Revoke 撤销用户的一个 Token，Token 不存在或已撤销时返回 ErrNotFound
+	Revoke(ctx context.Context, userID, id uint, now time.Time) (*model.PersonalAccessToken, error)
+}
+
+// IdentityStore 用户关联的第三方登录身份
+type IdentityStore interface {
+	// GetUser 按 (provider, subject) 读取已关联的用户，未关联时返回 ErrNotFound
+	GetUser(ctx context.Context, provider, subject string) (*model.User, error)
+	// Link 在同一事务中按邮箱（不区分大小写）查找用户，不存在时创建 user，再把 identity 关联到该用户；
+	// user 被替换为关联的用户。identity 已关联到其他用户时返回 ErrDuplicate
+	Link(ctx context.Context, user *model.User, identity *model.Identity) error
+	// List 返回用户关联的身份，按 ID 排序
+	List(ctx context.Context, userID uint) ([]model.Identity, error)
+	// Get 读取用户的一个身份，不存在（或不属于该用户）时返回 ErrNotFound
+	Get(ctx context.Context, userID, id uint) (*model.Identity, error)
+	// Delete 解除用户的一个身份关联，不存在时返回 ErrNotFound
+	Delete(ctx context.Context, userID, id uint) error
+}
+
+// AuthEventStore 认证审计记录，只追加
+type AuthEventStore interface {
+	Create(ctx context.Context, event *model.AuthEvent) error
+}
+
+// LoginAttemptStore 登录失败计数，保存在数据库中，多个实例共享同一份计数
+type LoginAttemptStore interface {
+	// Get 读取 subject 的记录，不存在时返回 ErrNotFound
+	Get(ctx context.Context, subject string) (*model.LoginAttempt, error)
+	// Fail 原子地记录一次失败并返回记录后的连续失败次数；上次失败早于 resetBefore 时从 1 重新计数
+	Fail(ctx context.Context, subject string, now, resetBefore time.Time) (int, error)
+	// Lock 把 subject 锁定到 until，已锁定到更晚的时间时不变
+	Lock(ctx context.Context, subject string, until time.Time) error
+	// Delete 删除 subject 的记录，不存在时不报错
+	Delete(ctx context.Context, subject string) error
+	// DeleteExpired 删除 prefix 开头、已解锁（now 之前）且上次失败早于 before 的记录，返回删除的数量
+	DeleteExpired(ctx context.Context, prefix string, now, before time.Time) (int64, error)
+}
@@ -0,0 +1,634 @@
+// Package storagetest 是 storage.Store 实现的一致性测试，每个实现的测试中调用 Run：
+//
+//	func TestStore(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Store { ... 返回一个已迁移的空数据库 ... })
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"todo-backend/internal/model"
+	"todo-backend/pkg/storage"
+)
+
+// Run 执行全部一致性测试。open 为每个子测试返回一个已完成迁移的空数据库，由调用方负责清理
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"Users", testUsers},
+		{"TwoFactor", testTwoFactor},
+		{"Todos", testTodos},
+		{"TodosChangedSince", testTodosChangedSince},
+		{"PurgeDeletedTodos", testPurgeDeletedTodos},
+		{"Sessions", testSessions},
+		{"RevokeSessions", testRevokeSessions},
+		{"Tokens", testTokens},
+		{"Identities", testIdentities},
+		{"AuthEvents", testAuthEvents},
+		{"LoginAttempts", testLoginAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s storage.Store, email string) *model.User {
+	t.Helper()
+	user := &model.User{Email: email, Password: "password", Name: email}
+	if err := s.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user
+}
+
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+func testUsers(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	if alice.ID == 0 || alice.ID == bob.ID {
+		t.Fatalf("user IDs not assigned: %d, %d", alice.ID, bob.ID)
+	}
+	if alice.Password == "password" || alice.CheckPassword("password") != nil {
+		t.Fatal("password was not hashed on create")
+	}
+
+	err := s.Users().Create(ctx, &model.User{Email: "alice@example.com", Password: "x"})
+	expectErr(t, err, storage.ErrDuplicate)
+
+	got, err := s.Users().Get(ctx, alice.ID)
+	if err != nil || got.Email != alice.Email || got.TOTPEnabled {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	got, err = s.Users().GetByEmail(ctx, "bob@example.com")
+	if err != nil || got.ID != bob.ID {
+		t.Fatalf("GetByEmail = %+v, %v", got, err)
+	}
+
+	_, err = s.Users().Get(ctx, bob.ID+100)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.Users().GetByEmail(ctx, "nobody@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	users, err := s.Users().ListByIDs(ctx, []uint{bob.ID, alice.ID, bob.ID + 100})
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ListByIDs = %+v, %v", users, err)
+	}
+	users, err = s.Users().ListByIDs(ctx, nil)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("ListByIDs(nil) = %+v, %v", users, err)
+	}
+}
+
+func testTwoFactor(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	users := s.Users()
+	user := createUser(t, s, "alice@example.com")
+
+	if err := users.SetTOTPSecret(ctx, user.ID, "SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, users.SetTOTPSecret(ctx, user.ID+100, "SECRET"), storage.ErrNotFound)
+
+	if ok, err := users.EnableTwoFactor(ctx, user.ID, 10, []string{"a", "b"}); err != nil || !ok {
+		t.Fatalf("EnableTwoFactor = %v, %v", ok, err)
+	}
+	if ok, err := users.EnableTwoFactor(ctx, user.ID, 11, []string{"x"}); err != nil || ok {
+		t.Fatalf("EnableTwoFactor while enabled = %v, %v; want false", ok, err)
+	}
+	got, _ := users.Get(ctx, user.ID)
+	if !got.TOTPEnabled || got.TOTPSecret != "SECRET" || got.TOTPLastStep != 10 {
+		t.Fatalf("after EnableTwoFactor: %+v", got)
+	}
+
+	// 时间步只能前进
+	for _, c := range []struct {
+		step int64
+		want bool
+	}{{10, false}, {9, false}, {11, true}, {11, false}} {
+		if ok, err := users.UseTOTPStep(ctx, user.ID, c.step); err != nil || ok != c.want {
+			t.Fatalf("UseTOTPStep(%d) = %v, %v; want %v", c.step, ok, err, c.want)
+		}
+	}
+
+	// 恢复码只能使用一次
+	now := time.Now()
+	for _, c := range []struct {
+		hash string
+		want bool
+	}{{"a", true}, {"a", false}, {"x", false}} {
+		if ok, err := users.UseRecoveryCode(ctx, user.ID, c.hash, now); err != nil || ok != c.want {
+			t.Fatalf("UseRecoveryCode(%q) = %v, %v; want %v", c.hash, ok, err, c.want)
+		}
+	}
+
+	// 已使用的时间步不能再换取恢复码，旧的恢复码保持有效
+	if ok, err := users.ReplaceRecoveryCodes(ctx, user.ID, 11, []string{"y"}); err != nil || ok {
+		t.Fatalf("ReplaceRecoveryCodes with a used step = %v, %v; want false", ok, err)
+	}
+	if ok, _ := users.UseRecoveryCode(ctx, user.ID, "y", now); ok {
+		t.Fatal("ReplaceRecoveryCodes replaced the codes with a used step")
+	}
+
+	// 替换后旧的恢复码失效
+	if ok, err := users.ReplaceRecoveryCodes(ctx, user.ID, 12, []string{"c"}); err != nil || !ok {
+		t.Fatalf("ReplaceRecoveryCodes = %v, %v", ok, err)
+	}
+	if ok, _ := users.UseRecoveryCode(ctx, user.ID, "b", now); ok {
+		t.Fatal("old recovery code still valid after ReplaceRecoveryCodes")
+	}
+	if ok, _ := users.UseTOTPStep(ctx, user.ID, 12); ok {
+		t.Fatal("ReplaceRecoveryCodes did not record the time step")
+	}
+
+	if err := users.DisableTwoFactor(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = users.Get(ctx, user.ID)
+	if got.TOTPEnabled || got.TOTPSecret != "" || got.TOTPLastStep != 0 {
+		t.Fatalf("after DisableTwoFactor: %+v", got)
+	}
+	if ok, _ := users.UseRecoveryCode(ctx, user.ID, "c", now); ok {
+		t.Fatal("recovery code still valid after DisableTwoFactor")
+	}
+}
+
+func testTodos(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	todos := s.Todos()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	todo := &model.Todo{UserID: alice.ID, LocalID: "local-1", Title: "first", DueDate: &due, IsFavorite: true}
+	if err := todos.Create(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.ID == 0 || todo.CreatedAt.IsZero() || todo.UpdatedAt.IsZero() {
+		t.Fatalf("Create did not fill ID and timestamps: %+v", todo)
+	}
+	if err := todos.Create(ctx, &model.Todo{UserID: bob.ID, Title: "bob's"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := todos.Get(ctx, alice.ID, todo.ID)
+	if err != nil || got.Title != "first" || !got.IsFavorite || got.DueDate == nil || !got.DueDate.Equal(due) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	// 读回的时间与写入时一致，否则同步游标会变化
+	if got.Cursor() != todo.Cursor() {
+		t.Fatalf("cursor changed after reading back: %s != %s", got.Cursor(), todo.Cursor())
+	}
+
+	_, err = todos.Get(ctx, bob.ID, todo.ID)
+	expectErr(t, err, storage.ErrNotFound)
+
+	got, err = todos.GetByLocalID(ctx, alice.ID, "local-1")
+	if err != nil || got.ID != todo.ID {
+		t.Fatalf("GetByLocalID = %+v, %v", got, err)
+	}
+	_, err = todos.GetByLocalID(ctx, bob.ID, "local-1")
+	expectErr(t, err, storage.ErrNotFound)
+
+	before := todo.UpdatedAt
+	time.Sleep(time.Millisecond)
+	todo.Title = "renamed"
+	todo.IsCompleted = true
+	if err := todos.Update(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+	if !todo.UpdatedAt.After(before) {
+		t.Fatal("Update did not advance UpdatedAt")
+	}
+	got, _ = todos.Get(ctx, alice.ID, todo.ID)
+	if got.Title != "renamed" || !got.IsCompleted {
+		t.Fatalf("after Update: %+v", got)
+	}
+
+	second := &model.Todo{UserID: alice.ID, Title: "second"}
+	if err := todos.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	list, err := todos.List(ctx, alice.ID)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	if err := todos.Delete(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if !second.DeletedAt.Valid {
+		t.Fatal("Delete did not set DeletedAt")
+	}
+	_, err = todos.Get(ctx, alice.ID, second.ID)
+	expectErr(t, err, storage.ErrNotFound)
+	list, _ = todos.List(ctx, alice.ID)
+	if len(list) != 1 || list[0].ID != todo.ID {
+		t.Fatalf("List after Delete = %+v", list)
+	}
+}
+
+func testTodosChangedSince(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	todos := s.Todos()
+	alice := createUser(t, s, "alice@example.com")
+
+	old := &model.Todo{UserID: alice.ID, Title: "unchanged"}
+	updated := &model.Todo{UserID: alice.ID, Title: "updated"}
+	deleted := &model.Todo{UserID: alice.ID, Title: "deleted"}
+	for _, todo := range []*model.Todo{old, updated, deleted} {
+		if err := todos.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	since, err := model.ParseCursor(deleted.Cursor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	updated.Title = "updated again"
+	if err := todos.Update(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Delete(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+	created := &model.Todo{UserID: alice.ID, Title: "created"}
+	if err := todos.Create(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := todos.ChangedSince(ctx, alice.ID, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[uint]model.Todo)
+	for _, todo := range changed {
+		byID[todo.ID] = todo
+	}
+	if len(byID) != 3 {
+		t.Fatalf("ChangedSince returned %d todos, want 3: %+v", len(byID), changed)
+	}
+	if _, ok := byID[old.ID]; ok {
+		t.Fatal("ChangedSince returned an unchanged todo")
+	}
+	if !byID[deleted.ID].DeletedAt.Valid {
+		t.Fatal("ChangedSince did not mark the deleted todo")
+	}
+	if byID[updated.ID].DeletedAt.Valid || byID[created.ID].Title != "created" {
+		t.Fatalf("ChangedSince returned wrong data: %+v", changed)
+	}
+
+	// 最新游标之后没有变更
+	latest := since
+	for _, todo := range changed {
+		if c, _ := model.ParseCursor(todo.Cursor()); c.After(latest) {
+			latest = c
+		}
+	}
+	if changed, err := todos.ChangedSince(ctx, alice.ID, latest); err != nil || len(changed) != 0 {
+		t.Fatalf("ChangedSince(latest) = %+v, %v", changed, err)
+	}
+}
+
+func testPurgeDeletedTodos(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	todos := s.Todos()
+	alice := createUser(t, s, "alice@example.com")
+
+	kept := &model.Todo{UserID: alice.ID, Title: "kept"}
+	purged := &model.Todo{UserID: alice.ID, Title: "purged"}
+	for _, todo := range []*model.Todo{kept, purged} {
+		if err := todos.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := todos.Delete(ctx, purged); err != nil {
+		t.Fatal(err)
+	}
+
+	// 截止时间之前没有删除的任务
+	n, err := todos.PurgeDeleted(ctx, purged.DeletedAt.Time.Add(-time.Second))
+	if err != nil || n != 0 {
+		t.Fatalf("PurgeDeleted(before delete) = %d, %v", n, err)
+	}
+	n, err = todos.PurgeDeleted(ctx, time.Now().Add(time.Second))
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v", n, err)
+	}
+
+	changed, err := todos.ChangedSince(ctx, alice.ID, time.Time{})
+	if err != nil || len(changed) != 1 || changed[0].ID != kept.ID {
+		t.Fatalf("ChangedSince after PurgeDeleted = %+v, %v", changed, err)
+	}
+}
+
+func testSessions(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	sessions := s.Sessions()
+	alice := createUser(t, s, "alice@example.com")
+	now := time.Now()
+
+	newSession := func(lastSeen, expires time.Time) *model.Session {
+		t.Helper()
+		session := &model.Session{UserID: alice.ID, UserAgent: "test", IP: "127.0.0.1", LastSeenAt: lastSeen, ExpiresAt: expires}
+		if err := sessions.Create(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	older := newSession(now.Add(-time.Hour), now.Add(time.Hour))
+	newer := newSession(now.Add(-time.Minute), now.Add(time.Hour))
+	expired := newSession(now.Add(-2*time.Hour), now.Add(-time.Minute)) // 已过期
+
+	got, err := sessions.Get(ctx, older.ID)
+	if err != nil || got.UserID != alice.ID || !got.Active(now) || !got.ExpiresAt.Equal(older.ExpiresAt.Round(time.Microsecond)) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	_, err = sessions.Get(ctx, older.ID+100)
+	expectErr(t, err, storage.ErrNotFound)
+
+	listed, err := sessions.ListByIDs(ctx, []uint{expired.ID, older.ID, older.ID + 100})
+	if err != nil || len(listed) != 2 || listed[0].ID != older.ID || listed[1].ID != expired.ID {
+		t.Fatalf("ListByIDs = %+v, %v", listed, err)
+	}
+	listed, err = sessions.ListByIDs(ctx, nil)
+	if err != nil || len(listed) != 0 {
+		t.Fatalf("ListByIDs(nil) = %+v, %v", listed, err)
+	}
+
+	active, err := sessions.ListActive(ctx, alice.ID, now)
+	if err != nil || len(active) != 2 || active[0].ID != newer.ID || active[1].ID != older.ID {
+		t.Fatalf("ListActive = %+v, %v", active, err)
+	}
+
+	if err := sessions.Touch(ctx, older.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	active, _ = sessions.ListActive(ctx, alice.ID, now)
+	if len(active) != 2 || active[0].ID != older.ID {
+		t.Fatalf("ListActive after Touch = %+v", active)
+	}
+}
+
+func testRevokeSessions(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	sessions := s.Sessions()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	now := time.Now()
+
+	var ids []uint
+	for _, userID := range []uint{alice.ID, alice.ID, alice.ID, bob.ID} {
+		session := &model.Session{UserID: userID, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := sessions.Create(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, session.ID)
+	}
+
+	_, err := sessions.Revoke(ctx, bob.ID, ids[0], now)
+	expectErr(t, err, storage.ErrNotFound)
+
+	revoked, err := sessions.Revoke(ctx, alice.ID, ids[0], now)
+	if err != nil || revoked.ID != ids[0] || revoked.RevokedAt == nil {
+		t.Fatalf("Revoke = %+v, %v", revoked, err)
+	}
+	_, err = sessions.Revoke(ctx, alice.ID, ids[0], now)
+	expectErr(t, err, storage.ErrNotFound)
+	if got, _ := sessions.Get(ctx, ids[0]); got.Active(now) {
+		t.Fatal("revoked session is still active")
+	}
+
+	others, err := sessions.RevokeOthers(ctx, alice.ID, ids[1], now)
+	if err != nil || len(others) != 1 || others[0].ID != ids[2] {
+		t.Fatalf("RevokeOthers = %+v, %v", others, err)
+	}
+	active, _ := sessions.ListActive(ctx, alice.ID, now)
+	if len(active) != 1 || active[0].ID != ids[1] {
+		t.Fatalf("ListActive after RevokeOthers = %+v", active)
+	}
+	if active, _ := sessions.ListActive(ctx, bob.ID, now); len(active) != 1 {
+		t.Fatal("RevokeOthers revoked another user's session")
+	}
+}
+
+func testTokens(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tokens := s.Tokens()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	now := time.Now()
+
+	newToken := func(userID uint, hash string) *model.PersonalAccessToken {
+		t.Helper()
+		expiresAt := now.Add(time.Hour)
+		token := &model.PersonalAccessToken{UserID: userID, Name: hash, TokenHash: hash, Prefix: "todo_", Scopes: []string{model.ScopeTodosRead}, ExpiresAt: &expiresAt}
+		if err := tokens.Create(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	first := newToken(alice.ID, "hash-1")
+	second := newToken(alice.ID, "hash-2")
+	other := newToken(bob.ID, "hash-3")
+
+	dup := &model.PersonalAccessToken{UserID: alice.ID, Name: "dup", TokenHash: "hash-1", Prefix: "todo_"}
+	expectErr(t, tokens.Create(ctx, dup), storage.ErrDuplicate)
+
+	got, err := tokens.GetByHash(ctx, "hash-2")
+	if err != nil || got.ID != second.ID || !got.HasScope(model.ScopeTodosRead) || !got.ExpiresAt.Equal(*second.ExpiresAt) {
+		t.Fatalf("GetByHash = %+v, %v", got, err)
+	}
+	_, err = tokens.GetByHash(ctx, "missing")
+	expectErr(t, err, storage.ErrNotFound)
+
+	if err := tokens.Touch(ctx, first.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tokens.GetByHash(ctx, "hash-1"); got.LastUsedAt == nil || !got.LastUsedAt.Equal(now.UTC().Round(time.Microsecond)) {
+		t.Fatalf("LastUsedAt after Touch = %v", got.LastUsedAt)
+	}
+
+	_, err = tokens.Revoke(ctx, bob.ID, first.ID, now)
+	expectErr(t, err, storage.ErrNotFound)
+	revoked, err := tokens.Revoke(ctx, alice.ID, first.ID, now)
+	if err != nil || revoked.ID != first.ID || revoked.RevokedAt == nil {
+		t.Fatalf("Revoke = %+v, %v", revoked, err)
+	}
+	_, err = tokens.Revoke(ctx, alice.ID, first.ID, now)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = tokens.GetByHash(ctx, "hash-1")
+	expectErr(t, err, storage.ErrNotFound)
+
+	listed, err := tokens.List(ctx, alice.ID)
+	if err != nil || len(listed) != 1 || listed[0].ID != second.ID {
+		t.Fatalf("List = %+v, %v", listed, err)
+	}
+
+	// ListByIDs 包括已撤销的 Token，供重新校验连接时区分撤销和不存在
+	listed, err = tokens.ListByIDs(ctx, []uint{other.ID, first.ID, other.ID + 100})
+	if err != nil || len(listed) != 2 || listed[0].ID != first.ID || listed[0].RevokedAt == nil || listed[1].ID != other.ID {
+		t.Fatalf("ListByIDs = %+v, %v", listed, err)
+	}
+	listed, err = tokens.ListByIDs(ctx, nil)
+	if err != nil || len(listed) != 0 {
+		t.Fatalf("ListByIDs(nil) = %+v, %v", listed, err)
+	}
+}
+
+func testIdentities(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	identities := s.Identities()
+	alice := createUser(t, s, "alice@example.com")
+
+	_, err := identities.GetUser(ctx, "google", "g-1")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// 邮箱不区分大小写地关联到现有用户
+	user := &model.User{Email: "Alice@Example.com", Name: "Alice"}
+	google := &model.Identity{Provider: "google", Subject: "g-1", Email: "alice@example.com"}
+	if err := identities.Link(ctx, user, google); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != alice.ID || google.UserID != alice.ID || google.ID == 0 {
+		t.Fatalf("Link to existing user: user %+v, identity %+v", user, google)
+	}
+	if got, err := identities.GetUser(ctx, "google", "g-1"); err != nil || got.ID != alice.ID {
+		t.Fatalf("GetUser = %+v, %v", got, err)
+	}
+
+	// 邮箱未注册时创建用户
+	carol := &model.User{Email: "carol@example.com", Name: "Carol"}
+	github := &model.Identity{Provider: "github", Subject: "gh-1", Email: "carol@example.com"}
+	if err := identities.Link(ctx, carol, github); err != nil {
+		t.Fatal(err)
+	}
+	if carol.ID == 0 || carol.ID == alice.ID || github.UserID != carol.ID {
+		t.Fatalf("Link creating a user: user %+v, identity %+v", carol, github)
+	}
+	if got, err := s.Users().GetByEmail(ctx, "carol@example.com"); err != nil || got.ID != carol.ID {
+		t.Fatalf("created user = %+v, %v", got, err)
+	}
+
+	// 同一身份不能关联两次，失败时不创建用户
+	dave := &model.User{Email: "dave@example.com"}
+	expectErr(t, identities.Link(ctx, dave, &model.Identity{Provider: "google", Subject: "g-1"}), storage.ErrDuplicate)
+	_, err = s.Users().GetByEmail(ctx, "dave@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	second := &model.Identity{Provider: "github", Subject: "gh-2"}
+	if err := identities.Link(ctx, &model.User{Email: "alice@example.com"}, second); err != nil {
+		t.Fatal(err)
+	}
+	listed, err := identities.List(ctx, alice.ID)
+	if err != nil || len(listed) != 2 || listed[0].ID != google.ID || listed[1].ID != second.ID {
+		t.Fatalf("List = %+v, %v", listed, err)
+	}
+
+	if got, err := identities.Get(ctx, alice.ID, google.ID); err != nil || got.Provider != "google" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	_, err = identities.Get(ctx, carol.ID, google.ID)
+	expectErr(t, err, storage.ErrNotFound)
+
+	expectErr(t, identities.Delete(ctx, carol.ID, google.ID), storage.ErrNotFound)
+	if err := identities.Delete(ctx, alice.ID, google.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, identities.Delete(ctx, alice.ID, google.ID), storage.ErrNotFound)
+	_, err = identities.GetUser(ctx, "google", "g-1")
+	expectErr(t, err, storage.ErrNotFound)
+}
+
+func testAuthEvents(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+
+	// 邮箱未注册的失败没有 UserID
+	for _, event := range []*model.AuthEvent{
+		{UserID: &alice.ID, Email: alice.Email, Event: model.AuthEventLoginSucceeded, IP: "127.0.0.1"},
+		{Email: "nobody@example.com", Event: model.AuthEventLoginFailed, IP: "127.0.0.1"},
+	} {
+		if err := s.AuthEvents().Create(ctx, event); err != nil {
+			t.Fatalf("Create(%s): %v", event.Event, err)
+		}
+		if event.ID == 0 || event.CreatedAt.IsZero() {
+			t.Fatalf("Create(%s) = %+v", event.Event, event)
+		}
+	}
+}
+
+func testLoginAttempts(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	attempts := s.LoginAttempts()
+	now := time.Now()
+
+	_, err := attempts.Get(ctx, "account:alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	for want := 1; want <= 3; want++ {
+		n, err := attempts.Fail(ctx, "account:alice@example.com", now, now.Add(-time.Hour))
+		if err != nil || n != want {
+			t.Fatalf("Fail #%d = %d, %v", want, n, err)
+		}
+	}
+	// 上次失败早于 resetBefore，从 1 重新计数
+	if n, err := attempts.Fail(ctx, "account:alice@example.com", now.Add(2*time.Hour), now.Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("Fail after reset = %d, %v", n, err)
+	}
+
+	until := now.Add(time.Minute)
+	if err := attempts.Lock(ctx, "account:alice@example.com", until); err != nil {
+		t.Fatal(err)
+	}
+	// 不会缩短已有的锁定
+	if err := attempts.Lock(ctx, "account:alice@example.com", now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := attempts.Get(ctx, "account:alice@example.com")
+	if err != nil || got.Failures != 1 || got.LockedUntil == nil || !got.LockedUntil.Equal(until.Round(time.Microsecond)) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	if _, err := attempts.Fail(ctx, "ip:127.0.0.1", now, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// 仍在锁定中的记录和其他前缀的记录不会被删除
+	n, err := attempts.DeleteExpired(ctx, "account:", now, now.Add(3*time.Hour))
+	if err != nil || n != 0 {
+		t.Fatalf("DeleteExpired while locked = %d, %v", n, err)
+	}
+	n, err = attempts.DeleteExpired(ctx, "account:", until.Add(time.Second), now.Add(3*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v", n, err)
+	}
+	if _, err := attempts.Get(ctx, "ip:127.0.0.1"); err != nil {
+		t.Fatalf("DeleteExpired removed another prefix: %v", err)
+	}
+
+	if err := attempts.Delete(ctx, "ip:127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = attempts.Get(ctx, "ip:127.0.0.1")
+	expectErr(t, err, storage.ErrNotFound)
+	if err := attempts.Delete(ctx, "ip:127.0.0.1"); err != nil {
+		t.Fatalf("Delete missing = %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Package tracing 初始化 OpenTelemetry 链路追踪。
+//
+// HTTP 请求（otelgin）、SQL 语句（GORM 插件）和 WebSocket 事件推送各自产生 span，
+// 通过全局的 TracerProvider 和 W3C Trace Context 传播器串联；事件经 backplane 转发到其他实例时，
+// 追踪上下文随 ws.Envelope 一起传递。未启用时使用 OpenTelemetry 默认的空实现，不产生开销。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// 导出方式
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"   // OTLP/HTTP，发往 Collector、Jaeger、Tempo 等
+	ExporterStdout = "stdout" // 每个 span 一行 JSON，写入文件或标准输出
+)
+
+// Options 导出设置
+type Options struct {
+	Exporter    string
+	ServiceName string
+	// Endpoint OTLP 接收地址，例如 http://localhost:4318；为空时使用 OTEL_EXPORTER_OTLP_* 环境变量或默认的 localhost:4318
+	Endpoint string
+	// File stdout 导出的目标文件，为空时写入标准输出
+	File string
+}
+
+// Init 设置全局 TracerProvider 和传播器，返回的函数在退出前调用，导出剩余的 span。
+// 采样方式沿用 OTEL_TRACES_SAMPLER、OTEL_TRACES_SAMPLER_ARG 环境变量，默认全部采样
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		if opts.File != "" {
+			f, ferr := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if ferr != nil {
+				return nil, fmt.Errorf("open trace file: %w", ferr)
+			}
+			w, closer = f, f
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
@@ -1,35 +1,158 @@
 package utils
 
 import (
-    "os"
-    "time"
+	"errors"
+	"time"
 
-    "github.com/golang-jwt/jwt/v5"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(userID uint) (string, error) {
-    claims := jwt.MapClaims{
-        "user_id": userID,
-        "exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7天过期
-    }
+// Token 类型，防止不同用途的 Token 被混用（例如两步验证的挑战 Token 被当作正式会话使用）
+const (
+	TokenTypeAccess             = "access"
+	TokenTypeTwoFactorChallenge = "2fa_challenge"
+	TokenTypeOAuthState         = "oauth_state"
+)
+
+var ErrInvalidTokenType = errors.New("invalid token type")
+
+// AccessTokenTTL 访问 Token 以及对应会话的有效期
+var AccessTokenTTL = time.Hour * 24 * 7 // 7天过期
+
+var jwtSecret []byte
+
+// InitJWT 设置签名密钥和访问 Token 有效期
+func InitJWT(secret string, accessTokenTTL time.Duration) {
+	jwtSecret = []byte(secret)
+	AccessTokenTTL = accessTokenTTL
+}
+
+// Claims 访问 Token 中携带的信息
+type Claims struct {
+	UserID    uint
+	SessionID uint
+	ExpiresAt time.Time
+}
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// GenerateToken 为指定会话签发访问 Token
+func GenerateToken(userID, sessionID uint) (string, error) {
+	return generateToken(jwt.MapClaims{
+		"user_id": userID,
+		"sid":     sessionID,
+	}, TokenTypeAccess, AccessTokenTTL)
 }
 
-func ValidateToken(tokenString string) (uint, error) {
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        return []byte(os.Getenv("JWT_SECRET")), nil
-    })
+// GenerateChallengeToken 生成登录第二步使用的短期挑战 Token
+func GenerateChallengeToken(userID uint) (string, error) {
+	return generateToken(jwt.MapClaims{"user_id": userID}, TokenTypeTwoFactorChallenge, time.Minute*5)
+}
+
+func generateToken(claims jwt.MapClaims, tokenType string, ttl time.Duration) (string, error) {
+	claims["typ"] = tokenType
+	claims["exp"] = time.Now().Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ParseToken 验证访问 Token 并返回其中的用户、会话和过期时间
+func ParseToken(tokenString string) (*Claims, error) {
+	claims, err := parseClaims(tokenString, TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	// 引入会话之前签发的 Token 没有 sid，无法被撤销，要求重新登录
+	sessionID, ok := claims["sid"].(float64)
+	if !ok || sessionID == 0 {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
 
-    if err != nil {
-        return 0, err
-    }
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return &Claims{
+		UserID:    uint(userID),
+		SessionID: uint(sessionID),
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
+
+// ValidateChallengeToken 验证两步验证挑战 Token 并返回用户 ID
+func ValidateChallengeToken(tokenString string) (uint, error) {
+	claims, err := parseClaims(tokenString, TokenTypeTwoFactorChallenge)
+	if err != nil {
+		return 0, err
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, jwt.ErrTokenInvalidClaims
+	}
+	return uint(userID), nil
+}
+
+// parseClaims 校验签名、过期时间和 Token 类型
+func parseClaims(tokenString, tokenType string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if typ, _ := claims["typ"].(string); typ != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+	return claims, nil
+}
+
+// OAuthState 第三方登录跳转前保存的状态，签名后存放在 Cookie 中
+type OAuthState struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string // PKCE code_verifier
+}
+
+// GenerateOAuthStateToken 签名 OAuth 登录状态，10 分钟内有效
+func GenerateOAuthStateToken(s OAuthState) (string, error) {
+	return generateToken(jwt.MapClaims{
+		"provider": s.Provider,
+		"state":    s.State,
+		"nonce":    s.Nonce,
+		"verifier": s.Verifier,
+	}, TokenTypeOAuthState, time.Minute*10)
+}
 
-    if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-        userID := uint(claims["user_id"].(float64))
-        return userID, nil
-    }
+// ValidateOAuthStateToken 验证并解析 OAuth 登录状态
+func ValidateOAuthStateToken(tokenString string) (*OAuthState, error) {
+	claims, err := parseClaims(tokenString, TokenTypeOAuthState)
+	if err != nil {
+		return nil, err
+	}
 
-    return 0, jwt.ErrSignatureInvalid
+	str := func(key string) string {
+		v, _ := claims[key].(string)
+		return v
+	}
+	return &OAuthState{
+		Provider: str("provider"),
+		State:    str("state"),
+		Nonce:    str("nonce"),
+		Verifier: str("verifier"),
+	}, nil
 }
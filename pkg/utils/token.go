@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix Personal Access Token 的固定前缀，AuthMiddleware 据此区分 JWT
+const PersonalAccessTokenPrefix = "tdp_"
+
+// GeneratePersonalAccessToken 生成 Token 明文，返回明文和用于存储的哈希
+func GeneratePersonalAccessToken() (string, string, error) {
+	raw := make([]byte, 30)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	token := PersonalAccessTokenPrefix + strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+	return token, HashPersonalAccessToken(token), nil
+}
+
+// HashPersonalAccessToken Token 为高熵随机值，使用 SHA-256 即可，便于按哈希直接查找
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsPersonalAccessToken 判断 Bearer 凭证是否为 Personal Access Token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
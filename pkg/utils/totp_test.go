@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA-1 测试向量的密钥 "12345678901234567890"
+var rfc6238Secret = base32NoPadding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// RFC 给出的是 8 位验证码，6 位验证码为其后 6 位
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	key, err := base32NoPadding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		step := tt.unix / totpPeriod
+		if got := totpCode(key, step); got != tt.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+		if got, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0)); !ok || got != step {
+			t.Errorf("ValidateTOTP(T=%d) = %d, %v; want %d, true", tt.unix, got, ok, step)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	key, _ := base32NoPadding.DecodeString(rfc6238Secret)
+
+	// 前后各一个时间窗口内的验证码有效，返回的是验证码所属的时间步
+	tests := []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, tt := range tests {
+		code := totpCode(key, current+tt.offset)
+		step, ok := ValidateTOTP(rfc6238Secret, code, now)
+		if ok != tt.ok || (ok && step != current+tt.offset) {
+			t.Errorf("code of step %+d: ValidateTOTP = %d, %v; want %d, %v", tt.offset, step, ok, current+tt.offset, tt.ok)
+		}
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	// ValidateTOTP 本身不记录状态，调用方用返回的时间步拒绝重放（UseTOTPStep 只接受更大的时间步）：
+	// 同一验证码在它有效的每个窗口内都对应同一时间步，下一窗口的新验证码时间步更大
+	now := time.Unix(1111111111, 0)
+	key, _ := base32NoPadding.DecodeString(rfc6238Secret)
+	code := totpCode(key, now.Unix()/totpPeriod)
+
+	first, ok := ValidateTOTP(rfc6238Secret, code, now)
+	if !ok {
+		t.Fatal("current code rejected")
+	}
+	later, ok := ValidateTOTP(rfc6238Secret, code, now.Add(totpPeriod*time.Second))
+	if !ok || later != first {
+		t.Fatalf("replayed code in the next window = %d, %v; want step %d", later, ok, first)
+	}
+
+	next := totpCode(key, first+1)
+	if step, ok := ValidateTOTP(rfc6238Secret, next, now.Add(totpPeriod*time.Second)); !ok || step <= first {
+		t.Fatalf("next code = %d, %v; want a step after %d", step, ok, first)
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"surrounding spaces", rfc6238Secret, " 287082 ", true},
+		{"lower-case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"too short", rfc6238Secret, "28708", false},
+		{"eight digits", rfc6238Secret, "94287082", false},
+		{"invalid secret", "not base32!", "287082", false},
+		{"empty", rfc6238Secret, "", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) || seen[code] {
+			t.Fatalf("recovery codes = %v", codes)
+		}
+		seen[code] = true
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// 哈希忽略大小写、空格和连字符
+	want := HashRecoveryCode("abcde-fghij")
+	for _, input := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde fghij "} {
+		if HashRecoveryCode(input) != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the normalized code", input)
+		}
+	}
+	if HashRecoveryCode("abcde-fghik") == want {
+		t.Error("different codes have the same hash")
+	}
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"context"
+	"time"
+)
+
+// reauth 命令由上层注册（需要校验 Token），ws 包只负责替换凭证和到期检查
+const (
+	TypeReauth        = "reauth"
+	TypeTokenExpiring = "token_expiring"
+)
+
+const (
+	// tokenWarning Token 到期前多久发送 token_expiring
+	tokenWarning = 5 * time.Minute
+	// revalidateInterval 重新校验会话和账号的间隔，覆盖账号被删除等没有主动通知的情况
+	revalidateInterval = time.Minute
+	// revalidateTimeout 一轮重新校验的超时，超时视为数据库暂时不可用
+	revalidateTimeout = 10 * time.Second
+)
+
+// Credentials 连接的用户和凭证：登录会话（SessionID）或 Personal Access Token（TokenID），未使用的一个为 0
+type Credentials struct {
+	UserID    uint
+	SessionID uint
+	TokenID   uint
+}
+
+// Validator 批量重新校验所有连接的凭证（每种凭证各查询一次），返回已失效的凭证及原因，
+// 对应的连接以 CloseSessionRevoked 关闭
+type Validator func(ctx context.Context, creds []Credentials) map[Credentials]error
+
+type tokenExpiring struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int       `json:"expires_in"`
+}
+
+// Reauthenticate 用新 Token 的会话和到期时间替换连接原有的凭证，
+// 由 reauth 命令在校验 Token 后调用
+func (m *WSManager) Reauthenticate(c *Client, sessionID uint, expiresAt time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	c.SessionID = sessionID
+	c.ExpiresAt = expiresAt
+	c.expiryWarned = false
+}
+
+// sweepExpired 关闭 Token 已过期的连接，并提醒即将过期的连接重新认证
+func (m *WSManager) sweepExpired(now time.Time) {
+	var expired []*Client
+	expiring := make(map[*Client]tokenExpiring)
+
+	m.mutex.Lock()
+	for _, clients := range m.Clients {
+		for client := range clients {
+			switch {
+			case client.ExpiresAt.IsZero():
+			case !now.Before(client.ExpiresAt):
+				expired = append(expired, client)
+			case !client.expiryWarned && client.ExpiresAt.Sub(now) <= tokenWarning:
+				client.expiryWarned = true
+				expiring[client] = tokenExpiring{
+					ExpiresAt: client.ExpiresAt,
+					ExpiresIn: int(client.ExpiresAt.Sub(now).Seconds()),
+				}
+			}
+		}
+	}
+	m.mutex.Unlock()
+
+	for client, warning := range expiring {
+		m.Send(client, Reply{Type: TypeTokenExpiring, Data: warning})
+	}
+	for _, client := range expired {
+		go client.Close(CloseTokenExpired, "token expired")
+	}
+}
+
+// revalidate 对所有连接调用 Validate，在单独的 goroutine 中执行以免阻塞 Run
+func (m *WSManager) revalidate() {
+	if m.Validate == nil {
+		return
+	}
+
+	m.mutex.RLock()
+	connections := make(map[Credentials][]*Client)
+	for _, clients := range m.Clients {
+		for client := range clients {
+			creds := Credentials{UserID: client.ID, SessionID: client.SessionID, TokenID: client.TokenID}
+			connections[creds] = append(connections[creds], client)
+		}
+	}
+	m.mutex.RUnlock()
+	if len(connections) == 0 {
+		return
+	}
+
+	list := make([]Credentials, 0, len(connections))
+	for creds := range connections {
+		list = append(list, creds)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+	defer cancel()
+
+	for creds, err := range m.Validate(ctx, list) {
+		for _, client := range connections[creds] {
+			logger.InfoContext(client.Context(), "Closing connection", "user_id", creds.UserID, "reason", err)
+			client.Close(CloseSessionRevoked, "session revoked")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Envelope 通过 backplane 在实例之间传递的事件
+type Envelope struct {
+	ID       string          `json:"id"`       // 事件唯一 ID，用于去重
+	Instance string          `json:"instance"` // 发布事件的实例
+	Topics   []string        `json:"topics"`
+	Payload  json.RawMessage `json:"payload"`
+	// Trace 发布方的追踪上下文（traceparent 等 W3C Trace Context 字段），接收方的 span 与之关联
+	Trace map[string]string `json:"trace,omitempty"`
+	// Presence 发布实例上的在线状态变化；Snapshot 为 true 时是该实例的全部状态（此时没有 Topics 和 Payload），
+	// 接收方据此合并其他实例上的在线用户
+	Presence []presenceChange `json:"presence,omitempty"`
+	Snapshot bool             `json:"snapshot,omitempty"`
+	// Revoke 发布实例上撤销的会话或 Token，接收方立即关闭自己持有的对应连接，不等下一次重新校验
+	Revoke *Revocation `json:"revoke,omitempty"`
+}
+
+// Backplane 多实例部署时的事件广播通道。每个实例把事件发布到 backplane，
+// 再由各实例分别推送给自己持有的连接
+type Backplane interface {
+	// Publish 把事件发布给所有实例（包括自己）
+	Publish(ctx context.Context, env Envelope) error
+	// Subscribe 开始接收事件，直到 ctx 结束
+	Subscribe(ctx context.Context, handler func(Envelope)) error
+	Close() error
+}
+
+// backplanePublishTimeout 发布到 backplane 的超时时间
+const backplanePublishTimeout = 5 * time.Second
+
+// backplaneQueueSize 等待发布到 backplane 的事件数上限。发布由单独的 goroutine 完成，不占用请求的处理时间；
+// backplane 持续变慢或不可用导致队列写满时丢弃新事件并计数
+const backplaneQueueSize = 1024
+
+// pendingEnvelope 等待发布的事件，ctx 只用于追踪和日志
+type pendingEnvelope struct {
+	ctx context.Context
+	env Envelope
+}
+
+// dedupSize 记录最近收到的事件 ID 数量
+const dedupSize = 4096
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	return host + "-" + randomID(4)
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dedup 固定容量的已见 ID 集合，超出容量时淘汰最早的
+type dedup struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+	order []string
+	next  int
+}
+
+func newDedup(size int) *dedup {
+	return &dedup{seen: make(map[string]bool, size), order: make([]string, size)}
+}
+
+// Seen 记录 id，返回此前是否已经见过
+func (d *dedup) Seen(id string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.seen[id] {
+		return true
+	}
+	if old := d.order[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.order[d.next] = id
+	d.next = (d.next + 1) % len(d.order)
+	d.seen[id] = true
+	return false
+}
+
+// UseBackplane 接入 backplane：本实例发布的事件同时发往 backplane，
+// 其他实例发布的事件推送给本实例的订阅者
+func (m *WSManager) UseBackplane(ctx context.Context, bp Backplane) error {
+	m.backplane = bp
+	m.dedup = newDedup(dedupSize)
+	m.outbox = make(chan pendingEnvelope, backplaneQueueSize)
+	if err := bp.Subscribe(ctx, m.receive); err != nil {
+		return err
+	}
+	go m.runPublisher(ctx)
+	go m.syncPresence(ctx)
+	return nil
+}
+
+// runPublisher 依次把 forward 排队的事件发布到 backplane，直到 ctx 结束
+func (m *WSManager) runPublisher(ctx context.Context) {
+	for {
+		select {
+		case pending := <-m.outbox:
+			// 发布不随请求取消，只保留追踪上下文和请求 ID
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(pending.ctx), backplanePublishTimeout)
+			if err := m.backplane.Publish(ctx, pending.env); err != nil {
+				logger.ErrorContext(ctx, "Failed to publish event to backplane", "error", err)
+			}
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive 处理 backplane 上的事件，本实例发布的事件已在本地推送过，直接跳过
+func (m *WSManager) receive(env Envelope) {
+	if env.Instance == m.InstanceID || m.dedup.Seen(env.ID) {
+		return
+	}
+	if len(env.Presence) > 0 || env.Snapshot {
+		m.remote.apply(env.Instance, env.Presence, env.Snapshot, time.Now())
+	}
+	if env.Revoke != nil {
+		m.closeRevoked(*env.Revoke)
+	}
+	if len(env.Topics) == 0 {
+		return
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(env.Trace))
+	ctx, span := tracer.Start(ctx, "ws.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.StringSlice("ws.topics", env.Topics),
+			attribute.String("ws.origin_instance", env.Instance),
+		))
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("ws.recipients", m.publish(ctx, env.Payload, nil, env.Topics...)))
+}
+
+// forward 把本地发布的事件排入发布队列，由 runPublisher 异步发到 backplane；
+// 填写 ID 和 Instance，ctx 中的追踪上下文写入 Envelope.Trace
+func (m *WSManager) forward(ctx context.Context, env Envelope) {
+	if m.backplane == nil {
+		return
+	}
+
+	env.ID = randomID(16)
+	env.Instance = m.InstanceID
+	env.Trace = make(map[string]string)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(env.Trace))
+	m.dedup.Seen(env.ID)
+
+	select {
+	case m.outbox <- pendingEnvelope{ctx: ctx, env: env}:
+	default:
+		m.backplaneDropped.Add(1)
+		logger.WarnContext(ctx, "Backplane publish queue is full, dropping event", "topics", env.Topics)
+	}
+}
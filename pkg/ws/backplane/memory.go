@@ -0,0 +1,59 @@
+// Package backplane 提供 ws.Backplane 的实现：基于 PostgreSQL LISTEN/NOTIFY 的 Postgres，
+// 以及在同一进程内模拟多实例的 Memory（用于测试和单实例部署）。
+package backplane
+
+import (
+	"context"
+	"sync"
+
+	"todo-backend/pkg/ws"
+)
+
+// MemoryHub 进程内的消息中心，同一个 Hub 创建的 Memory 之间互相可见
+type MemoryHub struct {
+	mutex   sync.RWMutex
+	members map[*Memory]func(ws.Envelope)
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{members: make(map[*Memory]func(ws.Envelope))}
+}
+
+// Memory 挂在 MemoryHub 上的 backplane，每个实例使用一个
+type Memory struct {
+	hub *MemoryHub
+}
+
+// Backplane 为一个实例创建 backplane
+func (h *MemoryHub) Backplane() *Memory {
+	return &Memory{hub: h}
+}
+
+func (m *Memory) Publish(ctx context.Context, env ws.Envelope) error {
+	m.hub.mutex.RLock()
+	defer m.hub.mutex.RUnlock()
+
+	for _, handler := range m.hub.members {
+		handler(env)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, handler func(ws.Envelope)) error {
+	m.hub.mutex.Lock()
+	m.hub.members[m] = handler
+	m.hub.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.Close()
+	}()
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.hub.mutex.Lock()
+	delete(m.hub.members, m)
+	m.hub.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,147 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-backend/pkg/logging"
+	"todo-backend/pkg/ws"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Channel LISTEN/NOTIFY 使用的频道
+	Channel = "todo_ws_events"
+
+	// NOTIFY 的 payload 上限为 8000 字节，超过阈值的事件先写入表中，通知只携带引用
+	maxNotifyPayload = 7000
+	refPrefix        = "ref:"
+	spillRetention   = 10 * time.Minute
+
+	maxReconnectDelay = 30 * time.Second
+)
+
+var logger = logging.For(logging.Backplane)
+
+// Postgres 基于 PostgreSQL LISTEN/NOTIFY 的 backplane
+type Postgres struct {
+	dsn  string
+	pool *pgxpool.Pool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPostgres 连接数据库。存放大事件的 ws_backplane_messages 表由数据库迁移创建
+func NewPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{dsn: dsn, pool: pool}, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, env ws.Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	payload := string(data)
+	if len(payload) > maxNotifyPayload {
+		if _, err := p.pool.Exec(ctx, "INSERT INTO ws_backplane_messages (id, payload) VALUES ($1, $2)", env.ID, payload); err != nil {
+			return fmt.Errorf("store large event: %w", err)
+		}
+		if _, err := p.pool.Exec(ctx, "DELETE FROM ws_backplane_messages WHERE created_at < $1", time.Now().Add(-spillRetention)); err != nil {
+			logger.WarnContext(ctx, "Failed to delete expired large events", "error", err)
+		}
+		payload = refPrefix + env.ID
+	}
+
+	_, err = p.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, payload)
+	return err
+}
+
+// Subscribe 使用独立连接 LISTEN，断线后按指数退避重连
+func (p *Postgres) Subscribe(ctx context.Context, handler func(ws.Envelope)) error {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		delay := time.Second
+		for {
+			err := p.listen(ctx, handler, func() { delay = time.Second })
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Warn("Backplane listener disconnected, reconnecting", "error", err, "delay", delay.String())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if delay < maxReconnectDelay {
+				delay *= 2
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *Postgres) listen(ctx context.Context, handler func(ws.Envelope), connected func()) error {
+	conn, err := pgx.Connect(ctx, p.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		env, err := p.decode(ctx, notification.Payload)
+		if err != nil {
+			logger.Warn("Dropping invalid backplane event", "error", err)
+			continue
+		}
+		handler(env)
+	}
+}
+
+func (p *Postgres) decode(ctx context.Context, payload string) (ws.Envelope, error) {
+	var env ws.Envelope
+
+	if id, ok := strings.CutPrefix(payload, refPrefix); ok {
+		if err := p.pool.QueryRow(ctx, "SELECT payload FROM ws_backplane_messages WHERE id = $1", id).Scan(&payload); err != nil {
+			return env, fmt.Errorf("load large event %s: %w", id, err)
+		}
+	}
+
+	err := json.Unmarshal([]byte(payload), &env)
+	return env, err
+}
+
+func (p *Postgres) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	p.pool.Close()
+	return nil
+}
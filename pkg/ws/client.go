@@ -1,23 +1,74 @@
 package ws
 
 import (
-	"log"
+	"context"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type Client struct {
-	ID     uint
-	Socket *websocket.Conn
-	Send   chan []byte
+	ID        uint
+	SessionID uint // 认证所用的登录会话，撤销会话时据此关闭连接
+	TokenID   uint // 认证所用的 Personal Access Token，撤销 Token 时据此关闭连接
+	Socket    *websocket.Conn
+	// ExpiresAt 认证所用 Token 的到期时间，到期后以 CloseTokenExpired 关闭连接；
+	// 注册后只能通过 Manager.Reauthenticate 修改
+	ExpiresAt time.Time
+	// Codec 通过子协议协商的消息编码，默认 JSON
+	Codec Codec
+
+	ctx          context.Context // 见 SetContext
+	queue        *sendQueue      // 待发送的消息，由 Manager 按慢消费者策略写入
+	expiryWarned bool            // 是否已发送 token_expiring
+	closeOnce    sync.Once
+	closed       chan struct{} // 没有 Socket 的客户端（SSE）通过它得知被服务端关闭
+	closeCode    int
+	closeMsg     string
+	topics       map[string]bool      // 已订阅的主题，由 Manager 在持锁时维护
+	heartbeats   map[string]heartbeat // 各主题的 viewing/editing 心跳
+}
+
+// NewClient 创建已认证的客户端，之后交给 Manager.Register 注册
+func NewClient(userID, sessionID uint, socket *websocket.Conn) *Client {
+	return &Client{
+		ID:        userID,
+		SessionID: sessionID,
+		Socket:    socket,
+		Codec:     JSONCodec,
+		queue:     newSendQueue(),
+		closed:    make(chan struct{}),
+	}
+}
+
+// SetContext 设置连接的 ctx，通常是建立连接的请求去掉取消后的 ctx（context.WithoutCancel），
+// 连接上的日志和命令处理因此带有该请求的 ID
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
 }
 
+// Context 连接的 ctx，未设置时为 context.Background()
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// 应用自定义的关闭码（4000-4999）
+const (
+	CloseSessionRevoked = 4001
+	CloseTokenExpired   = 4002
+	CloseSlowConsumer   = 4008
+)
+
 const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 16 * 1024
 )
 
 func (c *Client) ReadPump() {
@@ -37,14 +88,42 @@ func (c *Client) ReadPump() {
 		_, message, err := c.Socket.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				logger.WarnContext(c.Context(), "WebSocket closed unexpectedly", "user_id", c.ID, "error", err)
 			}
 			break
 		}
-		Manager.Broadcast <- message
+
+		// 处理命令并回复 ack/error，回复与推送一样由 WritePump 写出
+		message, err = c.Codec.Decode(message)
+		if err != nil {
+			Manager.Send(c, errorReply("", ErrCodeInvalidMessage, "Message could not be decoded"))
+			continue
+		}
+		Manager.Send(c, Manager.dispatch(c, message))
 	}
 }
 
+// writeMessage 按连接的编码写出一帧，连接写入失败时返回 false；无法编码的消息被跳过
+func (c *Client) writeMessage(message outbound) bool {
+	span := c.startDelivery(message, "websocket")
+	defer span.End()
+
+	frame, err := message.frame(c.Codec)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.ErrorContext(c.Context(), "Failed to encode message", "user_id", c.ID, "error", err)
+		return true
+	}
+
+	c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.Socket.WriteMessage(c.Codec.MessageType(), frame); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	Manager.sent.Add(1)
+	return true
+}
+
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -54,28 +133,23 @@ func (c *Client) WritePump() {
 
 	for {
 		select {
-		case message, ok := <-c.Send:
-			c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.queue.wake:
+			// 每条消息单独一帧，按连接的编码写出
+			for _, message := range c.queue.drain() {
+				if !c.writeMessage(message) {
+					return
+				}
 			}
 
-			w, err := c.Socket.NextWriter(websocket.TextMessage)
-			if err != nil {
+			if frame := c.queue.closeFrame(); frame != nil {
+				c.Close(frame.code, frame.reason)
 				return
 			}
-			w.Write(message)
 
-			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
-			}
-
-			if err := w.Close(); err != nil {
-				return
-			}
+		case <-c.queue.done:
+			c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 
 		case <-ticker.C:
 			c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
@@ -85,3 +159,36 @@ func (c *Client) WritePump() {
 		}
 	}
 }
+
+// CloseAfterFlush 先写出已排队的消息，再以 code 关闭连接
+func (c *Client) CloseAfterFlush(code int, reason string) {
+	if c.Socket == nil {
+		// EventPump 收到关闭信号后会先写出队列中的消息
+		c.Close(code, reason)
+		return
+	}
+
+	q := c.queue
+	q.mutex.Lock()
+	q.closing = &closeFrame{code: code, reason: reason}
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close 发送带关闭码的关闭帧后断开连接，ReadPump 随之退出并注销客户端。
+// WriteControl 可以与 WritePump 并发调用。没有 Socket 的客户端由 EventPump 发送关闭事件
+func (c *Client) Close(code int, reason string) {
+	if c.Socket == nil {
+		c.closeOnce.Do(func() {
+			c.closeCode, c.closeMsg = code, reason
+			close(c.closed)
+		})
+		return
+	}
+	c.Socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	c.Socket.Close()
+}
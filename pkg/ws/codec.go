@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 通过 Sec-WebSocket-Protocol 协商的子协议，未指定时使用 JSON
+const (
+	SubprotocolJSON    = "todo.v1.json"
+	SubprotocolMsgpack = "todo.v1.msgpack"
+)
+
+// Codec 连接使用的消息编码。事件在服务端内部（推送、backplane、SSE 补发）统一以 JSON 表示，
+// 只在写出和读入时转换；推送的事件每种编码只转换一次，由所有接收者共享
+type Codec interface {
+	// MessageType 写出时使用的帧类型
+	MessageType() int
+	// Encode 把 JSON 消息转换为连接的编码
+	Encode(message []byte) ([]byte, error)
+	// Decode 把客户端发来的帧转换为 JSON
+	Decode(frame []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	SubprotocolJSON:    jsonCodec{},
+	SubprotocolMsgpack: msgpackCodec{},
+}
+
+// JSONCodec 默认编码，也用于未协商子协议的旧客户端
+var JSONCodec Codec = jsonCodec{}
+
+// Subprotocols 服务端支持的子协议，按优先级排列，用于 websocket.Upgrader
+func Subprotocols() []string {
+	return []string{SubprotocolMsgpack, SubprotocolJSON}
+}
+
+// CodecFor 返回协商结果对应的编码，未协商时返回 JSONCodec
+func CodecFor(subprotocol string) Codec {
+	if codec, ok := codecs[subprotocol]; ok {
+		return codec
+	}
+	return JSONCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MessageType() int                      { return websocket.TextMessage }
+func (jsonCodec) Encode(message []byte) ([]byte, error) { return message, nil }
+func (jsonCodec) Decode(frame []byte) ([]byte, error)   { return frame, nil }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Encode(message []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(normalizeNumbers(v))
+}
+
+func (msgpackCodec) Decode(frame []byte) ([]byte, error) {
+	var v interface{}
+	if err := msgpack.Unmarshal(frame, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// normalizeNumbers 把 json.Number 转为整数或浮点数，使 MessagePack 使用数值类型而不是字符串
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeNumbers(value)
+		}
+	}
+	return v
+}
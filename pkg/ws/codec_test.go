@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	// want 为往返后的 JSON：键按字母排序，数值保持原值
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"reply", `{"type":"ack","id":"1","data":{"id":42,"done":true,"title":"x"}}`, `{"data":{"done":true,"id":42,"title":"x"},"id":"1","type":"ack"}`},
+		{"nested values", `{"items":[1,2.5,"a",null,false,{"k":[]}]}`, `{"items":[1,2.5,"a",null,false,{"k":[]}]}`},
+		{"integer beyond float64 precision", `{"seq":9007199254740993}`, `{"seq":9007199254740993}`},
+		{"negative and unicode", `{"n":-7,"s":"待办 ✓"}`, `{"n":-7,"s":"待办 ✓"}`},
+		{"exponent", `{"f":1e-7}`, `{"f":1e-7}`},
+		{"empty object", `{}`, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for subprotocol, codec := range codecs {
+				frame, err := codec.Encode([]byte(tt.message))
+				if err != nil {
+					t.Fatalf("%s: Encode: %v", subprotocol, err)
+				}
+				decoded, err := codec.Decode(frame)
+				if err != nil {
+					t.Fatalf("%s: Decode: %v", subprotocol, err)
+				}
+
+				want := tt.want
+				if codec == JSONCodec {
+					// JSON 原样传递
+					want = tt.message
+				}
+				if string(decoded) != want {
+					t.Errorf("%s: round trip = %s, want %s", subprotocol, decoded, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMsgpackEncodesNumbers(t *testing.T) {
+	frame, err := CodecFor(SubprotocolMsgpack).Encode([]byte(`{"id":42,"ratio":0.5,"seq":9007199254740993}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := msgpack.Unmarshal(frame, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数值以 MessagePack 的整数和浮点类型编码，而不是 json.Number 的字符串
+	for key, kinds := range map[string][]reflect.Kind{
+		"id":    {reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64},
+		"seq":   {reflect.Int64, reflect.Uint64},
+		"ratio": {reflect.Float32, reflect.Float64},
+	} {
+		kind := reflect.ValueOf(v[key]).Kind()
+		ok := false
+		for _, k := range kinds {
+			ok = ok || k == kind
+		}
+		if !ok {
+			t.Errorf("%s decoded as %T", key, v[key])
+		}
+	}
+	if v["seq"] != int64(9007199254740993) && v["seq"] != uint64(9007199254740993) {
+		t.Errorf("seq = %v, want 9007199254740993", v["seq"])
+	}
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		codec       Codec
+		messageType int
+	}{
+		{SubprotocolJSON, JSONCodec, websocket.TextMessage},
+		{SubprotocolMsgpack, msgpackCodec{}, websocket.BinaryMessage},
+		{"", JSONCodec, websocket.TextMessage},
+		{"todo.v2.unknown", JSONCodec, websocket.TextMessage},
+	}
+	for _, tt := range tests {
+		codec := CodecFor(tt.subprotocol)
+		if codec != tt.codec || codec.MessageType() != tt.messageType {
+			t.Errorf("CodecFor(%q) = %T (type %d), want %T (type %d)", tt.subprotocol, codec, codec.MessageType(), tt.codec, tt.messageType)
+		}
+	}
+}
+
+func TestMsgpackInvalidInput(t *testing.T) {
+	codec := CodecFor(SubprotocolMsgpack)
+	if _, err := codec.Encode([]byte(`{"type":`)); err == nil {
+		t.Error("Encode accepted invalid JSON")
+	}
+	if _, err := codec.Decode([]byte{0xc1}); err == nil {
+		t.Error("Decode accepted an invalid MessagePack frame")
+	}
+}
+
+// countingCodec 记录 Encode 的调用次数
+type countingCodec struct {
+	calls *int
+}
+
+func (countingCodec) MessageType() int                    { return websocket.BinaryMessage }
+func (countingCodec) Decode(frame []byte) ([]byte, error) { return frame, nil }
+func (c countingCodec) Encode(message []byte) ([]byte, error) {
+	*c.calls++
+	return append([]byte("encoded:"), message...), nil
+}
+
+func TestFrameEncodedOncePerCodec(t *testing.T) {
+	calls := 0
+	counting := countingCodec{calls: &calls}
+
+	// Publish 推送给所有接收者的消息共享 frameCache
+	shared := outbound{Data: []byte(`{"type":"todo_changed"}`), frames: &frameCache{}}
+	for i := 0; i < 3; i++ {
+		frame, err := shared.frame(counting)
+		if err != nil || string(frame) != `encoded:{"type":"todo_changed"}` {
+			t.Fatalf("frame = %s, %v", frame, err)
+		}
+		if frame, _ := shared.frame(JSONCodec); string(frame) != `{"type":"todo_changed"}` {
+			t.Fatalf("JSON frame = %s", frame)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("shared message encoded %d times, want 1", calls)
+	}
+
+	// 命令回复只发给一个客户端，不缓存
+	reply := outbound{Data: []byte(`{"type":"ack"}`)}
+	reply.frame(counting)
+	reply.frame(counting)
+	if calls != 3 {
+		t.Fatalf("encoded %d times in total, want 3", calls)
+	}
+}
@@ -0,0 +1,13 @@
+package ws
+
+import "time"
+
+// WaitClosed 等待没有 Socket 的客户端被服务端关闭，返回关闭码；超时未关闭时 ok 为 false
+func (c *Client) WaitClosed(timeout time.Duration) (code int, ok bool) {
+	select {
+	case <-c.closed:
+		return c.closeCode, true
+	case <-time.After(timeout):
+		return 0, false
+	}
+}
@@ -1,72 +1,214 @@
 package ws
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
+
+	"todo-backend/pkg/logging"
+
+	"go.opentelemetry.io/otel"
 )
 
 var (
 	Manager *WSManager
 	once    sync.Once
+	logger  = logging.For(logging.WS)
+	tracer  = otel.Tracer("todo-backend/pkg/ws")
 )
 
+// InitManager 创建全局 Manager，需另行启动 Manager.Run
 func InitManager() {
 	once.Do(func() {
 		Manager = NewManager()
-		go Manager.Run()
 	})
 }
 
 type WSManager struct {
 	Clients    map[uint]map[*Client]bool
-	Broadcast  chan []byte
 	Register   chan *Client
 	Unregister chan *Client
 	mutex      sync.RWMutex
+
+	commands map[string]CommandHandler
+	topics   map[string]map[*Client]bool // 主题 -> 订阅的客户端
+
+	// Authorize 检查订阅权限，由上层在启动时设置
+	Authorize Authorizer
+	// Validate 定期重新校验连接的会话和账号，由上层在启动时设置
+	Validate Validator
+
+	// InstanceID 当前实例的唯一标识，backplane 据此跳过自己发布的事件
+	InstanceID string
+	backplane  Backplane
+	outbox     chan pendingEnvelope
+	dedup      *dedup
+	replay     *replayLog
+	remote     *remotePresence
+
+	// Policy 客户端发送队列满时的处理方式，默认丢弃最早的消息
+	Policy SlowConsumerPolicy
+	// SendBuffer 每个客户端发送队列的容量
+	SendBuffer int
+	// BufferLimit Policy 为 Buffer 时队列的最大长度，超过后断开连接
+	BufferLimit int
+	counters
+
+	draining bool // Shutdown 之后为 true，新连接直接收到 going_away
+	probe    chan chan struct{}
 }
 
 func NewManager() *WSManager {
-	return &WSManager{
-		Clients:    make(map[uint]map[*Client]bool),
-		Broadcast:  make(chan []byte),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
+	m := &WSManager{
+		Clients:     make(map[uint]map[*Client]bool),
+		Register:    make(chan *Client),
+		Unregister:  make(chan *Client),
+		commands:    make(map[string]CommandHandler),
+		topics:      make(map[string]map[*Client]bool),
+		InstanceID:  newInstanceID(),
+		replay:      newReplayLog(replaySize),
+		remote:      newRemotePresence(),
+		Policy:      DropOldest,
+		SendBuffer:  defaultSendBuffer,
+		BufferLimit: defaultBufferLimit,
+		probe:       make(chan chan struct{}),
 	}
+	m.Handle(TypeSubscribe, m.handleSubscribe)
+	m.Handle(TypeUnsubscribe, m.handleUnsubscribe)
+	m.Handle(TypePresence, m.handlePresence)
+	return m
 }
 
-func (m *WSManager) Run() {
+// Run 处理注册、注销和定时任务，直到 ctx 结束
+func (m *WSManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(presenceSweepInterval)
+	defer ticker.Stop()
+	revalidateTicker := time.NewTicker(revalidateInterval)
+	defer revalidateTicker.Stop()
+
 	for {
 		select {
 		case client := <-m.Register:
 			m.mutex.Lock()
-			if _, ok := m.Clients[client.ID]; !ok {
-				m.Clients[client.ID] = make(map[*Client]bool)
-			}
-			m.Clients[client.ID][client] = true
+			m.register(client)
+			draining := m.draining
 			m.mutex.Unlock()
+			if draining {
+				m.goAway(client)
+			}
 
 		case client := <-m.Unregister:
+			// 断开连接时离开所有主题，其他订阅者收到 presence_changed
+			var pending []pendingPresence
 			m.mutex.Lock()
 			if _, ok := m.Clients[client.ID]; ok {
 				if _, ok := m.Clients[client.ID][client]; ok {
 					delete(m.Clients[client.ID], client)
-					close(client.Send)
+					for topic := range client.topics {
+						m.trackPresence(&pending, client, topic, func() { m.unsubscribe(client, topic) })
+					}
+					client.queue.close()
 				}
 			}
 			m.mutex.Unlock()
+			m.publishPresence(pending)
 
-		case message := <-m.Broadcast:
-			m.mutex.RLock()
-			for _, clients := range m.Clients {
-				for client := range clients {
-					select {
-					case client.Send <- message:
-					default:
-						close(client.Send)
-						delete(clients, client)
-					}
-				}
-			}
-			m.mutex.RUnlock()
+		case now := <-ticker.C:
+			m.sweepPresence(now)
+			m.sweepExpired(now)
+
+		case <-revalidateTicker.C:
+			go m.revalidate()
+
+		case reply := <-m.probe:
+			close(reply)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ErrNotRunning Run 已退出或长时间阻塞
+var ErrNotRunning = errors.New("ws manager loop is not responding")
+
+// Ping 检查 Run 的循环是否仍在处理事件，ctx 结束前没有响应时返回 ErrNotRunning
+func (m *WSManager) Ping(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case m.probe <- reply:
+	case <-ctx.Done():
+		return ErrNotRunning
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ErrNotRunning
+	}
+}
+
+// register 添加客户端，调用方需持有写锁
+func (m *WSManager) register(c *Client) {
+	if _, ok := m.Clients[c.ID]; !ok {
+		m.Clients[c.ID] = make(map[*Client]bool)
+	}
+	m.Clients[c.ID][c] = true
+	// 每个连接自动订阅自己的 user 主题
+	m.subscribe(c, UserTopic(c.ID))
+}
+
+// SessionConnections 返回指定会话当前的 WebSocket 连接数
+func (m *WSManager) SessionConnections(userID, sessionID uint) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	count := 0
+	for client := range m.Clients[userID] {
+		if client.SessionID == sessionID {
+			count++
+		}
+	}
+	return count
+}
+
+// CloseSession 关闭指定会话的所有连接，并经 backplane 通知其他实例关闭它们持有的连接
+func (m *WSManager) CloseSession(userID, sessionID uint, code int, reason string) {
+	m.revoke(Revocation{UserID: userID, SessionID: sessionID, Code: code, Reason: reason})
+}
+
+// CloseToken 关闭使用指定 Personal Access Token 认证的连接，其他实例同样经 backplane 关闭
+func (m *WSManager) CloseToken(userID, tokenID uint, code int, reason string) {
+	m.revoke(Revocation{UserID: userID, TokenID: tokenID, Code: code, Reason: reason})
+}
+
+// Revocation 被撤销的会话或 Token（SessionID 和 TokenID 只设置其一），持有其连接的实例以 Code 关闭连接
+type Revocation struct {
+	UserID    uint   `json:"user_id"`
+	SessionID uint   `json:"session_id,omitempty"`
+	TokenID   uint   `json:"token_id,omitempty"`
+	Code      int    `json:"code"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (m *WSManager) revoke(r Revocation) {
+	m.closeRevoked(r)
+	m.forward(context.Background(), Envelope{Revoke: &r})
+}
+
+// closeRevoked 关闭本实例上属于 r 的连接
+func (m *WSManager) closeRevoked(r Revocation) {
+	m.mutex.RLock()
+	var clients []*Client
+	for client := range m.Clients[r.UserID] {
+		if (r.SessionID != 0 && client.SessionID == r.SessionID) || (r.TokenID != 0 && client.TokenID == r.TokenID) {
+			clients = append(clients, client)
 		}
 	}
+	m.mutex.RUnlock()
+
+	for _, client := range clients {
+		client.Close(r.Code, r.Reason)
+	}
 }
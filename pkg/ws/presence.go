@@ -0,0 +1,320 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 在线状态，按 editing > viewing > online 的优先级合并同一用户的多个连接
+const (
+	PresenceOffline = "offline"
+	PresenceOnline  = "online"
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+)
+
+const (
+	TypePresence        = "presence"
+	TypePresenceChanged = "presence_changed"
+
+	ErrCodeNotSubscribed = "not_subscribed"
+)
+
+// presenceTimeout viewing/editing 心跳的有效期，客户端应在此之前重复发送
+const presenceTimeout = 30 * time.Second
+
+// presenceSweepInterval 检查心跳过期的间隔
+const presenceSweepInterval = 5 * time.Second
+
+// presenceSyncInterval 接入 backplane 时向其他实例发送本实例全部在线状态的间隔；
+// 超过 remotePresenceTTL 没有消息的实例视为已下线，其上的用户不再出现在快照中
+const (
+	presenceSyncInterval = 15 * time.Second
+	remotePresenceTTL    = 3 * presenceSyncInterval
+)
+
+var presenceRank = map[string]int{
+	PresenceOffline: 0,
+	PresenceOnline:  1,
+	PresenceViewing: 2,
+	PresenceEditing: 3,
+}
+
+// PresenceEntry 某个用户在主题中的状态
+type PresenceEntry struct {
+	UserID uint   `json:"user_id"`
+	State  string `json:"state"`
+}
+
+type heartbeat struct {
+	state   string
+	expires time.Time
+}
+
+type presenceCommand struct {
+	Topic string `json:"topic"`
+	State string `json:"state"`
+}
+
+type presenceChange struct {
+	Topic  string `json:"topic"`
+	UserID uint   `json:"user_id"`
+	State  string `json:"state"`
+}
+
+// pendingPresence 在持锁期间收集的状态变化，释放锁后再推送
+type pendingPresence struct {
+	change presenceChange
+	origin *Client
+}
+
+// tracksPresence 用户主题是私有的，不记录在线状态
+func tracksPresence(topic string) bool {
+	kind, _, err := ParseTopic(topic)
+	return err == nil && kind != TopicUser
+}
+
+// userPresence 合并用户在主题中所有连接的状态，调用方需持有锁。
+// 过期的心跳由 sweepPresence 统一清除，这里不再判断有效期
+func (m *WSManager) userPresence(topic string, userID uint) string {
+	state := PresenceOffline
+	for client := range m.topics[topic] {
+		if client.ID != userID {
+			continue
+		}
+
+		clientState := PresenceOnline
+		if hb, ok := client.heartbeats[topic]; ok {
+			clientState = hb.state
+		}
+		if presenceRank[clientState] > presenceRank[state] {
+			state = clientState
+		}
+	}
+	return state
+}
+
+// trackPresence 执行一次修改，并在用户的合并状态发生变化时记录待推送的事件。调用方需持有写锁
+func (m *WSManager) trackPresence(pending *[]pendingPresence, c *Client, topic string, mutate func()) {
+	if !tracksPresence(topic) {
+		mutate()
+		return
+	}
+
+	before := m.userPresence(topic, c.ID)
+	mutate()
+	after := m.userPresence(topic, c.ID)
+
+	if before != after {
+		*pending = append(*pending, pendingPresence{
+			change: presenceChange{Topic: topic, UserID: c.ID, State: after},
+			origin: c,
+		})
+	}
+}
+
+// publishPresence 把状态变化推送给主题的其他订阅者，并经 backplane 发给其他实例
+func (m *WSManager) publishPresence(pending []pendingPresence) {
+	for _, p := range pending {
+		message, _ := json.Marshal(Reply{Type: TypePresenceChanged, Data: p.change})
+		m.publish(context.Background(), message, p.origin, p.change.Topic)
+		m.forward(context.Background(), Envelope{
+			Topics:   []string{p.change.Topic},
+			Payload:  message,
+			Presence: []presenceChange{p.change},
+		})
+	}
+}
+
+// syncPresence 定期把本实例的全部在线状态发给其他实例，直到 ctx 结束。
+// 单个变化可能因 backplane 断线丢失，快照保证各实例最终一致，并让下线实例的状态过期
+func (m *WSManager) syncPresence(ctx context.Context) {
+	ticker := time.NewTicker(presenceSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.forward(ctx, Envelope{Presence: m.localPresence(), Snapshot: true})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// localPresence 本实例所有主题上的用户状态
+func (m *WSManager) localPresence() []presenceChange {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	changes := []presenceChange{}
+	for topic, clients := range m.topics {
+		if !tracksPresence(topic) {
+			continue
+		}
+		seen := make(map[uint]bool)
+		for client := range clients {
+			if seen[client.ID] {
+				continue
+			}
+			seen[client.ID] = true
+			changes = append(changes, presenceChange{Topic: topic, UserID: client.ID, State: m.userPresence(topic, client.ID)})
+		}
+	}
+	return changes
+}
+
+// handlePresence 处理客户端的 viewing/editing 心跳
+func (m *WSManager) handlePresence(c *Client, data json.RawMessage) (interface{}, error) {
+	var cmd presenceCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, &CommandError{Code: ErrCodeInvalidMessage, Message: "topic and state are required"}
+	}
+	if cmd.State != PresenceViewing && cmd.State != PresenceEditing && cmd.State != PresenceOnline {
+		return nil, &CommandError{Code: ErrCodeInvalidMessage, Message: "state must be one of online, viewing, editing"}
+	}
+	if !tracksPresence(cmd.Topic) {
+		return nil, &CommandError{Code: ErrCodeInvalidTopic, Message: "Invalid topic: " + cmd.Topic}
+	}
+
+	var pending []pendingPresence
+	m.mutex.Lock()
+	if !c.topics[cmd.Topic] {
+		m.mutex.Unlock()
+		return nil, &CommandError{Code: ErrCodeNotSubscribed, Message: "Subscribe to the topic before sending presence"}
+	}
+
+	m.trackPresence(&pending, c, cmd.Topic, func() {
+		if cmd.State == PresenceOnline {
+			delete(c.heartbeats, cmd.Topic)
+			return
+		}
+		if c.heartbeats == nil {
+			c.heartbeats = make(map[string]heartbeat)
+		}
+		c.heartbeats[cmd.Topic] = heartbeat{state: cmd.State, expires: time.Now().Add(presenceTimeout)}
+	})
+	m.mutex.Unlock()
+
+	m.publishPresence(pending)
+	return map[string]interface{}{
+		"topic":      cmd.Topic,
+		"state":      cmd.State,
+		"expires_in": int(presenceTimeout.Seconds()),
+	}, nil
+}
+
+// sweepPresence 清除过期的心跳，状态回落为 online
+func (m *WSManager) sweepPresence(now time.Time) {
+	var pending []pendingPresence
+
+	m.mutex.Lock()
+	for _, clients := range m.Clients {
+		for client := range clients {
+			for topic, hb := range client.heartbeats {
+				if now.Before(hb.expires) {
+					continue
+				}
+				m.trackPresence(&pending, client, topic, func() {
+					delete(client.heartbeats, topic)
+				})
+			}
+		}
+	}
+	m.mutex.Unlock()
+
+	m.publishPresence(pending)
+}
+
+// Presence 返回主题当前的在线用户快照，包括经 backplane 得知的其他实例上的用户
+func (m *WSManager) Presence(topic string) []PresenceEntry {
+	states := m.remote.topic(topic, time.Now())
+
+	m.mutex.RLock()
+	for client := range m.topics[topic] {
+		if state := m.userPresence(topic, client.ID); presenceRank[state] > presenceRank[states[client.ID]] {
+			states[client.ID] = state
+		}
+	}
+	m.mutex.RUnlock()
+
+	entries := []PresenceEntry{}
+	for userID, state := range states {
+		entries = append(entries, PresenceEntry{UserID: userID, State: state})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UserID < entries[j].UserID })
+	return entries
+}
+
+// IsOnline 用户当前是否有 WebSocket 连接
+func (m *WSManager) IsOnline(userID uint) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.Clients[userID]) > 0
+}
+
+// remotePresence 其他实例上的在线状态，按实例分别记录
+type remotePresence struct {
+	mutex     sync.Mutex
+	instances map[string]*instancePresence
+}
+
+type instancePresence struct {
+	topics map[string]map[uint]string // 主题 -> 用户 -> 状态
+	seen   time.Time
+}
+
+func newRemotePresence() *remotePresence {
+	return &remotePresence{instances: make(map[string]*instancePresence)}
+}
+
+// apply 记录 instance 发来的状态变化；snapshot 为 true 时替换该实例的全部状态
+func (r *remotePresence) apply(instance string, changes []presenceChange, snapshot bool, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p := r.instances[instance]
+	if p == nil || snapshot {
+		p = &instancePresence{topics: make(map[string]map[uint]string)}
+		r.instances[instance] = p
+	}
+	p.seen = now
+
+	for _, change := range changes {
+		if change.State == PresenceOffline {
+			delete(p.topics[change.Topic], change.UserID)
+			if len(p.topics[change.Topic]) == 0 {
+				delete(p.topics, change.Topic)
+			}
+			continue
+		}
+		if p.topics[change.Topic] == nil {
+			p.topics[change.Topic] = make(map[uint]string)
+		}
+		p.topics[change.Topic][change.UserID] = change.State
+	}
+}
+
+// topic 合并各实例上主题的用户状态，顺带清除过期的实例
+func (r *remotePresence) topic(topic string, now time.Time) map[uint]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	states := make(map[uint]string)
+	for instance, p := range r.instances {
+		if now.Sub(p.seen) > remotePresenceTTL {
+			delete(r.instances, instance)
+			continue
+		}
+		for userID, state := range p.topics[topic] {
+			if presenceRank[state] > presenceRank[states[userID]] {
+				states[userID] = state
+			}
+		}
+	}
+	return states
+}
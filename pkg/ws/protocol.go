@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/json"
+)
+
+// 服务端回复的消息类型
+const (
+	TypeAck   = "ack"
+	TypeError = "error"
+)
+
+// 错误码
+const (
+	ErrCodeInvalidMessage   = "invalid_message"
+	ErrCodeUnknownType      = "unknown_type"
+	ErrCodeValidationFailed = "validation_failed"
+	ErrCodeInternal         = "internal_error"
+)
+
+// Request 客户端发送的命令，ID 由客户端生成，用于关联服务端的 ack/error 回复
+type Request struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Reply 服务端对命令的回复
+type Reply struct {
+	Type  string      `json:"type"`
+	ID    string      `json:"id,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+	Error *ReplyError `json:"error,omitempty"`
+}
+
+type ReplyError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CommandError 命令处理函数返回的带错误码的错误，其它错误统一回复 internal_error
+type CommandError struct {
+	Code    string
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+// CommandHandler 处理一种命令，返回值作为 ack 的 data
+type CommandHandler func(c *Client, data json.RawMessage) (interface{}, error)
+
+// Handle 注册命令处理函数，需在开始接受连接之前完成注册
+func (m *WSManager) Handle(msgType string, handler CommandHandler) {
+	m.commands[msgType] = handler
+}
+
+// dispatch 解析客户端消息并交给对应的处理函数，未知类型直接拒绝
+func (m *WSManager) dispatch(c *Client, message []byte) Reply {
+	var req Request
+	if err := json.Unmarshal(message, &req); err != nil || req.Type == "" {
+		return errorReply("", ErrCodeInvalidMessage, "Message must be a JSON object with a type")
+	}
+	if req.ID == "" {
+		return errorReply("", ErrCodeInvalidMessage, "Message id is required")
+	}
+
+	handler, ok := m.commands[req.Type]
+	if !ok {
+		return errorReply(req.ID, ErrCodeUnknownType, "Unknown message type: "+req.Type)
+	}
+
+	data, err := handler(c, req.Data)
+	if err != nil {
+		if cmdErr, ok := err.(*CommandError); ok {
+			return errorReply(req.ID, cmdErr.Code, cmdErr.Message)
+		}
+		logger.ErrorContext(c.Context(), "WebSocket command failed", "command", req.Type, "user_id", c.ID, "error", err)
+		return errorReply(req.ID, ErrCodeInternal, "Internal server error")
+	}
+
+	return Reply{Type: TypeAck, ID: req.ID, Data: data}
+}
+
+func errorReply(id, code, message string) Reply {
+	return Reply{Type: TypeError, ID: id, Error: &ReplyError{Code: code, Message: message}}
+}
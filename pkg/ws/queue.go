@@ -0,0 +1,320 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlowConsumerPolicy 客户端发送队列已满时的处理方式
+type SlowConsumerPolicy string
+
+const (
+	// DropOldest 丢弃队列中最早的事件，保留最新的；命令回复和控制消息不丢弃，
+	// 丢弃后客户端收到 resync_required
+	DropOldest SlowConsumerPolicy = "drop_oldest"
+	// Disconnect 直接断开连接，客户端重连后重新拉取数据
+	Disconnect SlowConsumerPolicy = "disconnect"
+	// Buffer 队列满后继续缓存，直到 BufferLimit 才断开连接
+	Buffer SlowConsumerPolicy = "buffer"
+)
+
+const (
+	defaultSendBuffer  = 256
+	defaultBufferLimit = 4096
+)
+
+// Stats 连接和慢消费者的统计
+type Stats struct {
+	Connections  int    `json:"connections"`
+	Users        int    `json:"users"`
+	Sent         uint64 `json:"sent"` // 已写出给客户端的消息数
+	Dropped      uint64 `json:"dropped"`
+	Disconnected uint64 `json:"disconnected"`
+	// BackplaneDropped 因发布队列已满没有发到 backplane 的事件数
+	BackplaneDropped uint64 `json:"backplane_dropped"`
+	// Queued 所有客户端发送队列中等待写出的消息数，MaxQueued 为其中最长的队列
+	Queued    int `json:"queued"`
+	MaxQueued int `json:"max_queued"`
+}
+
+// outbound 待发送的消息，Seq 为事件序号（命令回复为 0），SSE 用作事件 ID
+type outbound struct {
+	Seq  uint64
+	Data []byte
+	// Span 发布事件的 span，写出时的 ws.deliver span 链接到它；命令回复等为空
+	Span trace.SpanContext
+
+	frames *frameCache // 推送给多个客户端时共享，为空时每次编码
+}
+
+// frameCache 一条消息按编码缓存的帧，同一事件的所有接收者每种编码只转换一次
+type frameCache struct {
+	mutex  sync.Mutex
+	frames map[Codec]encodedFrame
+}
+
+type encodedFrame struct {
+	data []byte
+	err  error
+}
+
+// startDelivery 开始写出一条消息的 ws.deliver span，父 span 为建立连接的请求，并链接到发布事件的 span。
+// 不是由 Publish 产生的消息不记录，返回的 span 不做任何事
+func (c *Client) startDelivery(message outbound, transport string) trace.Span {
+	if !message.Span.IsValid() {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := tracer.Start(c.Context(), "ws.deliver",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithLinks(trace.Link{SpanContext: message.Span}),
+		trace.WithAttributes(
+			attribute.String("ws.transport", transport),
+			attribute.Int64("ws.seq", int64(message.Seq)),
+			attribute.Int64("ws.user_id", int64(c.ID)),
+		))
+	return span
+}
+
+// frame 返回消息按 codec 编码后的帧
+func (o outbound) frame(codec Codec) ([]byte, error) {
+	if o.frames == nil {
+		return codec.Encode(o.Data)
+	}
+
+	o.frames.mutex.Lock()
+	defer o.frames.mutex.Unlock()
+	if f, ok := o.frames.frames[codec]; ok {
+		return f.data, f.err
+	}
+	if o.frames.frames == nil {
+		o.frames.frames = make(map[Codec]encodedFrame)
+	}
+	data, err := codec.Encode(o.Data)
+	o.frames.frames[codec] = encodedFrame{data, err}
+	return data, err
+}
+
+// sendQueue 客户端的发送队列，由 WritePump 读取。
+// 与 channel 不同，注销后再写入不会 panic，满时按策略处理而不会阻塞发送方
+type sendQueue struct {
+	mutex         sync.Mutex
+	messages      []outbound
+	closed        bool
+	disconnecting bool          // 已因慢消费者决定断开，之后的消息直接丢弃，只计数和关闭一次
+	holding       bool          // 正在发送快照，暂存实时消息
+	resyncQueued  bool          // 丢弃事件后已排入 resync_required，drain 之前不再重复
+	closing       *closeFrame   // 发送完已排队的消息后关闭连接
+	wake          chan struct{} // 有新消息时通知 WritePump
+	done          chan struct{} // 客户端已注销
+}
+
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// drain 取出当前所有待发送的消息，暂存期间不返回
+func (q *sendQueue) drain() []outbound {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.holding {
+		return nil
+	}
+
+	messages := q.messages
+	q.messages = nil
+	q.resyncQueued = false
+	return messages
+}
+
+// resyncRequired 丢弃事件后发送的消息，与 SSE 无法补发时相同
+var resyncRequired = []byte(`{"type":"` + TypeResyncRequired + `"}`)
+
+// dropOldest 为 message 腾出位置：丢弃最早的事件（Seq 不为 0），队列中没有事件时丢弃 message 本身。
+// 命令回复和 token_expiring、going_away 等控制消息没有序号，无法补发，总是保留；
+// 队列中全是这类消息且已达到 limit 时 ok 为 false，调用方应断开连接。
+// 有事件被丢弃时 dropped 为 true，并排入一条 resync_required。调用方需持有 mutex
+func (q *sendQueue) dropOldest(message outbound, limit int) (dropped, ok bool) {
+	if i := slices.IndexFunc(q.messages, func(m outbound) bool { return m.Seq != 0 }); i >= 0 {
+		q.messages = append(slices.Delete(q.messages, i, i+1), message)
+	} else if message.Seq == 0 {
+		if len(q.messages) >= limit {
+			return false, false
+		}
+		q.messages = append(q.messages, message)
+		return false, true
+	}
+
+	if !q.resyncQueued {
+		q.messages = append(q.messages, outbound{Data: resyncRequired})
+		q.resyncQueued = true
+	}
+	return true, true
+}
+
+// closeFrame 返回 CloseAfterFlush 设置的关闭帧，暂存快照期间不关闭
+func (q *sendQueue) closeFrame() *closeFrame {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.holding || len(q.messages) > 0 {
+		return nil
+	}
+	return q.closing
+}
+
+func (q *sendQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+}
+
+// SendToUser 把事件推送给用户的所有连接（包括其他实例上的连接）
+func (m *WSManager) SendToUser(ctx context.Context, userID uint, event interface{}) {
+	m.Publish(ctx, event, UserTopic(userID))
+}
+
+// Send 向单个客户端发送事件，例如命令的回复
+func (m *WSManager) Send(c *Client, event interface{}) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		logger.ErrorContext(c.Context(), "Failed to marshal event", "error", err)
+		return
+	}
+	m.deliver(c, outbound{Data: message})
+}
+
+// deliver 把消息放入客户端的发送队列，队列已满时按 Policy 处理
+func (m *WSManager) deliver(c *Client, message outbound) {
+	q := c.queue
+	q.mutex.Lock()
+	if q.closed || q.disconnecting {
+		q.mutex.Unlock()
+		return
+	}
+
+	disconnect := false
+	switch {
+	case q.holding:
+		// 暂存期间不能丢弃消息，否则快照之后会出现缺口
+		if len(q.messages) < m.BufferLimit {
+			q.messages = append(q.messages, message)
+		} else {
+			disconnect = true
+		}
+	case len(q.messages) < m.SendBuffer:
+		q.messages = append(q.messages, message)
+	case m.Policy == DropOldest:
+		if dropped, ok := q.dropOldest(message, m.BufferLimit); !ok {
+			disconnect = true
+		} else if dropped {
+			m.dropped.Add(1)
+		}
+	case m.Policy == Buffer && len(q.messages) < m.BufferLimit:
+		q.messages = append(q.messages, message)
+	default:
+		disconnect = true
+	}
+	q.disconnecting = disconnect
+	q.mutex.Unlock()
+
+	if disconnect {
+		m.disconnected.Add(1)
+		logger.WarnContext(c.Context(), "Disconnecting slow client", "user_id", c.ID, "policy", string(m.Policy))
+		// Close 可能因写超时阻塞，不在调用方（可能持有锁）中同步执行
+		go c.Close(CloseSlowConsumer, "slow consumer")
+		return
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Hold 暂存之后推送给客户端的消息，直到 Release。需在注册客户端之前调用，
+// 保证读取快照期间发生的变更都被暂存下来
+func (c *Client) Hold() {
+	c.queue.mutex.Lock()
+	defer c.queue.mutex.Unlock()
+	c.queue.holding = true
+}
+
+// Release 先发送快照消息，再发送暂存的实时消息。covered 返回 true 的暂存消息已包含在快照中，不再重复发送
+func (m *WSManager) Release(c *Client, snapshot [][]byte, covered func(message []byte) bool) {
+	q := c.queue
+	q.mutex.Lock()
+	messages := make([]outbound, 0, len(snapshot)+len(q.messages))
+	for _, data := range snapshot {
+		messages = append(messages, outbound{Data: data})
+	}
+	for _, message := range q.messages {
+		if message.Seq == 0 || !covered(message.Data) {
+			messages = append(messages, message)
+		}
+	}
+	q.messages = messages
+	q.holding = false
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats 返回当前连接数和慢消费者统计
+func (m *WSManager) Stats() Stats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := Stats{
+		Sent:         m.sent.Load(),
+		Dropped:      m.dropped.Load(),
+		Disconnected: m.disconnected.Load(),
+
+		BackplaneDropped: m.backplaneDropped.Load(),
+	}
+	for _, clients := range m.Clients {
+		if len(clients) > 0 {
+			stats.Users++
+			stats.Connections += len(clients)
+		}
+		for client := range clients {
+			client.queue.mutex.Lock()
+			queued := len(client.queue.messages)
+			client.queue.mutex.Unlock()
+
+			stats.Queued += queued
+			stats.MaxQueued = max(stats.MaxQueued, queued)
+		}
+	}
+	return stats
+}
+
+// counters 发送、慢消费者和 backplane 丢弃计数，嵌入 WSManager
+type counters struct {
+	sent         atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+
+	backplaneDropped atomic.Uint64
+}
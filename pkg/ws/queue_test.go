@@ -0,0 +1,194 @@
+package ws
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func event(seq uint64) outbound {
+	return outbound{Seq: seq, Data: []byte(fmt.Sprintf("e%d", seq))}
+}
+
+// control 命令回复或控制消息，没有序号
+func control(name string) outbound {
+	return outbound{Data: []byte(name)}
+}
+
+// queued 客户端发送队列中的消息，resync_required 记为 "resync"
+func queued(c *Client) []string {
+	c.queue.mutex.Lock()
+	defer c.queue.mutex.Unlock()
+
+	out := make([]string, len(c.queue.messages))
+	for i, message := range c.queue.messages {
+		out[i] = string(message.Data)
+		if out[i] == string(resyncRequired) {
+			out[i] = "resync"
+		}
+	}
+	return out
+}
+
+func newQueueManager(policy SlowConsumerPolicy) *WSManager {
+	m := NewManager()
+	m.Policy = policy
+	m.SendBuffer = 3
+	m.BufferLimit = 5
+	return m
+}
+
+func TestDeliverSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       SlowConsumerPolicy
+		messages     []outbound
+		want         []string
+		dropped      uint64
+		disconnected uint64
+	}{
+		{
+			name:     "within send buffer",
+			policy:   DropOldest,
+			messages: []outbound{event(1), control("ack"), event(2)},
+			want:     []string{"e1", "ack", "e2"},
+		},
+		{
+			name:     "drop oldest keeps replies",
+			policy:   DropOldest,
+			messages: []outbound{control("ack"), event(1), event(2), event(3)},
+			want:     []string{"ack", "e2", "e3", "resync"},
+			dropped:  1,
+		},
+		{
+			name:     "drop oldest queues one resync",
+			policy:   DropOldest,
+			messages: []outbound{event(1), event(2), event(3), event(4), event(5)},
+			want:     []string{"e3", "e4", "resync", "e5"},
+			dropped:  2,
+		},
+		{
+			name:     "drop oldest keeps control messages when full",
+			policy:   DropOldest,
+			messages: []outbound{event(1), event(2), event(3), control("going_away")},
+			want:     []string{"e2", "e3", "going_away", "resync"},
+			dropped:  1,
+		},
+		{
+			name:     "drop oldest drops a new event when only replies are queued",
+			policy:   DropOldest,
+			messages: []outbound{control("a1"), control("a2"), control("a3"), event(1), control("a4")},
+			want:     []string{"a1", "a2", "a3", "resync", "a4"},
+			dropped:  1,
+		},
+		{
+			name:         "drop oldest disconnects beyond the buffer limit",
+			policy:       DropOldest,
+			messages:     []outbound{control("a1"), control("a2"), control("a3"), control("a4"), control("a5"), control("a6"), event(1)},
+			want:         []string{"a1", "a2", "a3", "a4", "a5"},
+			disconnected: 1,
+		},
+		{
+			name:         "disconnect",
+			policy:       Disconnect,
+			messages:     []outbound{event(1), event(2), event(3), event(4), event(5)},
+			want:         []string{"e1", "e2", "e3"},
+			disconnected: 1,
+		},
+		{
+			name:     "buffer up to the limit",
+			policy:   Buffer,
+			messages: []outbound{event(1), event(2), event(3), event(4), control("ack")},
+			want:     []string{"e1", "e2", "e3", "e4", "ack"},
+		},
+		{
+			name:         "buffer disconnects beyond the limit",
+			policy:       Buffer,
+			messages:     []outbound{event(1), event(2), event(3), event(4), event(5), event(6), event(7)},
+			want:         []string{"e1", "e2", "e3", "e4", "e5"},
+			disconnected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newQueueManager(tt.policy)
+			c := NewClient(1, 1, nil)
+			for _, message := range tt.messages {
+				m.deliver(c, message)
+			}
+
+			if got := queued(c); !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %v, want %v", got, tt.want)
+			}
+			if got := m.dropped.Load(); got != tt.dropped {
+				t.Errorf("dropped = %d, want %d", got, tt.dropped)
+			}
+			if got := m.disconnected.Load(); got != tt.disconnected {
+				t.Errorf("disconnected = %d, want %d", got, tt.disconnected)
+			}
+		})
+	}
+}
+
+func TestDropOldestResyncAfterDrain(t *testing.T) {
+	m := newQueueManager(DropOldest)
+	c := NewClient(1, 1, nil)
+	for seq := uint64(1); seq <= 4; seq++ {
+		m.deliver(c, event(seq))
+	}
+	c.queue.drain()
+
+	// 写出之后再次丢弃是新的缺口，需要再发一次 resync_required
+	for seq := uint64(5); seq <= 8; seq++ {
+		m.deliver(c, event(seq))
+	}
+	if got, want := queued(c), []string{"e6", "e7", "e8", "resync"}; !slices.Equal(got, want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+}
+
+func TestHoldRelease(t *testing.T) {
+	m := newQueueManager(DropOldest)
+	c := NewClient(1, 1, nil)
+	c.Hold()
+
+	// 暂存期间超过 SendBuffer 也不丢弃，drain 不返回任何消息
+	for _, message := range []outbound{event(1), control("ack"), event(2), event(3), event(4)} {
+		m.deliver(c, message)
+	}
+	if got := c.queue.drain(); got != nil {
+		t.Fatalf("drain while holding = %v", got)
+	}
+	if m.dropped.Load() != 0 {
+		t.Fatal("messages were dropped while holding")
+	}
+
+	// 快照在前；已包含在快照中的事件不再发送，命令回复总是保留
+	covered := func(data []byte) bool { return string(data) == "e1" || string(data) == "e3" }
+	m.Release(c, [][]byte{[]byte("snapshot"), []byte("snapshot_complete")}, covered)
+	if got, want := queued(c), []string{"snapshot", "snapshot_complete", "ack", "e2", "e4"}; !slices.Equal(got, want) {
+		t.Fatalf("queue after Release = %v, want %v", got, want)
+	}
+
+	// Release 之后恢复按策略处理
+	m.deliver(c, event(5))
+	if got, want := queued(c), []string{"snapshot", "snapshot_complete", "ack", "e4", "e5", "resync"}; !slices.Equal(got, want) {
+		t.Fatalf("queue after a drop = %v, want %v", got, want)
+	}
+}
+
+func TestHoldDisconnectsBeyondBufferLimit(t *testing.T) {
+	m := newQueueManager(DropOldest)
+	c := NewClient(1, 1, nil)
+	c.Hold()
+	for seq := uint64(1); seq <= 6; seq++ {
+		m.deliver(c, event(seq))
+	}
+	if m.disconnected.Load() != 1 || m.dropped.Load() != 0 {
+		t.Fatalf("disconnected = %d, dropped = %d; want 1, 0", m.disconnected.Load(), m.dropped.Load())
+	}
+	if code, ok := c.WaitClosed(time.Second); !ok || code != CloseSlowConsumer {
+		t.Fatalf("closed = %v, code %d", ok, code)
+	}
+}
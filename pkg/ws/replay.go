@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// replaySize 保留的最近事件数，SSE 客户端断线重连时据此补发
+const replaySize = 1024
+
+// TypeResyncRequired 无法补发断线期间的事件，或发送队列已满丢弃了事件时发送，客户端应重新拉取全部数据
+const TypeResyncRequired = "resync_required"
+
+type replayEntry struct {
+	seq    uint64
+	topics []string
+	data   []byte
+}
+
+// replayLog 最近发布的事件。mutex 在分配序号到推送完成期间一直持有，
+// 保证每个客户端收到的事件序号递增
+type replayLog struct {
+	mutex   sync.Mutex
+	seq     uint64
+	entries []replayEntry
+	next    int
+}
+
+func newReplayLog(size int) *replayLog {
+	return &replayLog{entries: make([]replayEntry, 0, size)}
+}
+
+// append 记录事件并返回分配的序号，调用方需持有 mutex
+func (r *replayLog) append(topics []string, data []byte) uint64 {
+	r.seq++
+	entry := replayEntry{seq: r.seq, topics: topics, data: data}
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, entry)
+	} else {
+		r.entries[r.next] = entry
+		r.next = (r.next + 1) % len(r.entries)
+	}
+	return r.seq
+}
+
+// since 按顺序返回序号大于 seq 且属于任一主题的事件；
+// seq 之后的事件已被淘汰时 ok 为 false。调用方需持有 mutex
+func (r *replayLog) since(seq uint64, topics map[string]bool) (entries []replayEntry, ok bool) {
+	if seq > r.seq {
+		return nil, false
+	}
+	if seq == r.seq {
+		return nil, true
+	}
+
+	oldest := r.entries[r.next].seq
+	if seq+1 < oldest {
+		return nil, false
+	}
+
+	for i := range r.entries {
+		entry := r.entries[(r.next+i)%len(r.entries)]
+		if entry.seq <= seq {
+			continue
+		}
+		for _, topic := range entry.topics {
+			if topics[topic] {
+				entries = append(entries, entry)
+				break
+			}
+		}
+	}
+	return entries, true
+}
+
+// EventID 事件 ID 由实例标识和序号组成，服务重启或连接到其他实例后旧 ID 不再有效
+func (m *WSManager) EventID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", m.InstanceID, seq)
+}
+
+// parseEventID 解析本实例签发的事件 ID
+func (m *WSManager) parseEventID(id string) (uint64, bool) {
+	i := strings.LastIndex(id, "-")
+	if i < 0 || id[:i] != m.InstanceID {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	return seq, err == nil
+}
+
+// Connect 注册没有 WebSocket 的客户端（例如 SSE），订阅 topics 并补发 lastEventID 之后的事件。
+// 注册、订阅和补发在同一把锁内完成，不会与实时推送交错或遗漏。
+// topics 应已通过 Authorize 校验；lastEventID 无法识别或已过期时推送 resync_required。
+// 返回当前最新事件的 ID，客户端在收到任何事件前断线也能从这里续传
+func (m *WSManager) Connect(c *Client, topics []string, lastEventID string) string {
+	var pending []pendingPresence
+
+	m.mutex.Lock()
+	m.register(c)
+	for _, topic := range topics {
+		m.trackPresence(&pending, c, topic, func() { m.subscribe(c, topic) })
+	}
+
+	m.replay.mutex.Lock()
+	current := m.EventID(m.replay.seq)
+	if lastEventID != "" {
+		seq, ok := m.parseEventID(lastEventID)
+		var entries []replayEntry
+		if ok {
+			entries, ok = m.replay.since(seq, c.topics)
+		}
+		if ok {
+			for _, entry := range entries {
+				m.deliver(c, outbound{Seq: entry.seq, Data: entry.data})
+			}
+		} else {
+			m.deliver(c, outbound{Data: resyncRequired})
+		}
+	}
+	m.replay.mutex.Unlock()
+	draining := m.draining
+	m.mutex.Unlock()
+
+	m.publishPresence(pending)
+	if draining {
+		m.goAway(c)
+	}
+	return current
+}
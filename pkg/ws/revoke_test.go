@@ -0,0 +1,73 @@
+package ws_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"todo-backend/pkg/ws"
+	"todo-backend/pkg/ws/backplane"
+)
+
+// startInstances 启动 n 个通过同一个内存 backplane 互通的 Manager
+func startInstances(t *testing.T, n int) []*ws.WSManager {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := backplane.NewMemoryHub()
+	managers := make([]*ws.WSManager, n)
+	for i := range managers {
+		m := ws.NewManager()
+		if err := m.UseBackplane(ctx, hub.Backplane()); err != nil {
+			t.Fatal(err)
+		}
+		go m.Run(ctx)
+		managers[i] = m
+	}
+	return managers
+}
+
+// register 在 m 上注册客户端，返回时注册已经完成
+func register(t *testing.T, m *ws.WSManager, clients ...*ws.Client) {
+	t.Helper()
+	for _, c := range clients {
+		m.Register <- c
+	}
+	if err := m.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevocationClosesConnectionsOnOtherInstances(t *testing.T) {
+	instances := startInstances(t, 2)
+	local, remote := instances[0], instances[1]
+
+	revoked := ws.NewClient(1, 7, nil)
+	otherSession := ws.NewClient(1, 8, nil)
+	pat := ws.NewClient(1, 0, nil)
+	pat.TokenID = 3
+	otherUser := ws.NewClient(2, 7, nil)
+	register(t, remote, revoked, otherSession, pat, otherUser)
+
+	sameInstance := ws.NewClient(1, 7, nil)
+	register(t, local, sameInstance)
+
+	local.CloseSession(1, 7, ws.CloseSessionRevoked, "session revoked")
+	for name, c := range map[string]*ws.Client{"local": sameInstance, "remote": revoked} {
+		if code, ok := c.WaitClosed(time.Second); !ok || code != ws.CloseSessionRevoked {
+			t.Fatalf("%s connection of the revoked session: closed = %v, code %d", name, ok, code)
+		}
+	}
+
+	local.CloseToken(1, 3, ws.CloseSessionRevoked, "token revoked")
+	if code, ok := pat.WaitClosed(time.Second); !ok || code != ws.CloseSessionRevoked {
+		t.Fatalf("connection of the revoked token: closed = %v, code %d", ok, code)
+	}
+
+	for name, c := range map[string]*ws.Client{"other session": otherSession, "other user": otherUser} {
+		if _, ok := c.WaitClosed(50 * time.Millisecond); ok {
+			t.Fatalf("%s was closed", name)
+		}
+	}
+}
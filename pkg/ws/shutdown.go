@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TypeGoingAway 服务端关闭前发送给每个连接，reconnect_after_ms 为建议的重连延迟
+const TypeGoingAway = "going_away"
+
+// reconnectWindow 客户端重连延迟的分布范围，避免所有客户端同时涌向新实例
+const reconnectWindow = 5 * time.Second
+
+type goingAway struct {
+	ReconnectAfter int64 `json:"reconnect_after_ms"`
+}
+
+// Shutdown 通知所有连接服务即将关闭并断开，等待它们全部注销或 ctx 结束。
+// 调用后新建立的连接也会立即收到 going_away
+func (m *WSManager) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	m.draining = true
+	var clients []*Client
+	for _, userClients := range m.Clients {
+		for client := range userClients {
+			clients = append(clients, client)
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, client := range clients {
+		m.goAway(client)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if m.Stats().Connections == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// goAway 发送 going_away 和带随机延迟的重连建议，然后以 1001 关闭连接
+func (m *WSManager) goAway(c *Client) {
+	m.Send(c, Reply{Type: TypeGoingAway, Data: goingAway{
+		ReconnectAfter: rand.Int63n(reconnectWindow.Milliseconds()),
+	}})
+	c.CloseAfterFlush(websocket.CloseGoingAway, "server shutting down")
+}
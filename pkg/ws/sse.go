@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// sseHeartbeat SSE 心跳间隔，比常见代理的空闲超时短
+const sseHeartbeat = 15 * time.Second
+
+// TypeClose 服务端关闭 SSE 连接前发送的事件，携带与 WebSocket 相同的关闭码
+const TypeClose = "close"
+
+// EventPump 把客户端的消息以 Server-Sent Events 格式写出，直到 ctx 结束或客户端被关闭。
+// current 为 Connect 返回的事件 ID，最先写出，作为续传的起点
+func (c *Client) EventPump(ctx context.Context, w io.Writer, flush func(), current string) {
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	fmt.Fprintf(w, "retry: 3000\nid: %s\n\n", current)
+	flush()
+
+	for {
+		select {
+		case <-c.queue.wake:
+			for _, message := range c.queue.drain() {
+				if !c.writeEvent(w, message) {
+					return
+				}
+			}
+			flush()
+
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flush()
+
+		case <-c.closed:
+			for _, message := range c.queue.drain() {
+				writeSSE(w, Manager.EventID(message.Seq), message)
+			}
+			data, _ := json.Marshal(Reply{
+				Type: TypeClose,
+				Data: map[string]interface{}{"code": c.closeCode, "reason": c.closeMsg},
+			})
+			writeSSE(w, "", outbound{Data: data})
+			flush()
+			return
+
+		case <-c.queue.done:
+			return
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeEvent 写出一条排队的消息，连接写入失败时返回 false
+func (c *Client) writeEvent(w io.Writer, message outbound) bool {
+	span := c.startDelivery(message, "sse")
+	defer span.End()
+
+	if err := writeSSE(w, Manager.EventID(message.Seq), message); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	Manager.sent.Add(1)
+	return true
+}
+
+// writeSSE 写出一条事件，命令回复等没有序号的消息不带 id，避免覆盖客户端的续传位置
+func writeSSE(w io.Writer, id string, message outbound) error {
+	var buf bytes.Buffer
+	if message.Seq != 0 && id != "" {
+		fmt.Fprintf(&buf, "id: %s\n", id)
+	}
+	// data 中的换行需拆成多行 data 字段
+	for _, line := range bytes.Split(message.Data, []byte{'\n'}) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,186 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 主题类型
+const (
+	TopicUser = "user"
+	TopicList = "list"
+	TopicTodo = "todo"
+)
+
+// 订阅相关的命令和错误码
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+
+	ErrCodeInvalidTopic  = "invalid_topic"
+	ErrCodeForbidden     = "forbidden"
+	ErrCodeTopicNotFound = "topic_not_found"
+	ErrCodeTooManyTopics = "too_many_topics"
+)
+
+// maxTopicsPerClient 单个连接最多订阅的主题数（不含自动订阅的 user 主题）
+const maxTopicsPerClient = 100
+
+var ErrInvalidTopic = errors.New("invalid topic")
+
+// Authorizer 检查客户端能否订阅主题，返回 *CommandError 时原样回复给客户端
+type Authorizer func(c *Client, kind string, id uint) error
+
+func UserTopic(id uint) string { return fmt.Sprintf("%s:%d", TopicUser, id) }
+func ListTopic(id uint) string { return fmt.Sprintf("%s:%d", TopicList, id) }
+func TodoTopic(id uint) string { return fmt.Sprintf("%s:%d", TopicTodo, id) }
+
+// ParseTopic 解析 "<kind>:<id>" 形式的主题
+func ParseTopic(topic string) (string, uint, error) {
+	kind, rawID, ok := strings.Cut(topic, ":")
+	if !ok {
+		return "", 0, ErrInvalidTopic
+	}
+
+	switch kind {
+	case TopicUser, TopicList, TopicTodo:
+	default:
+		return "", 0, ErrInvalidTopic
+	}
+
+	id, err := strconv.ParseUint(rawID, 10, 32)
+	if err != nil || id == 0 {
+		return "", 0, ErrInvalidTopic
+	}
+	return kind, uint(id), nil
+}
+
+type topicCommand struct {
+	Topic string `json:"topic"`
+}
+
+// handleSubscribe 校验权限后把客户端加入主题
+func (m *WSManager) handleSubscribe(c *Client, data json.RawMessage) (interface{}, error) {
+	var cmd topicCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, &CommandError{Code: ErrCodeInvalidTopic, Message: "topic is required"}
+	}
+
+	kind, id, err := ParseTopic(cmd.Topic)
+	if err != nil {
+		return nil, &CommandError{Code: ErrCodeInvalidTopic, Message: "Invalid topic: " + cmd.Topic}
+	}
+
+	if m.Authorize == nil {
+		return nil, &CommandError{Code: ErrCodeForbidden, Message: "Subscriptions are not available"}
+	}
+	if err := m.Authorize(c, kind, id); err != nil {
+		return nil, err
+	}
+
+	var pending []pendingPresence
+	m.mutex.Lock()
+	if !c.topics[cmd.Topic] && len(c.topics) > maxTopicsPerClient {
+		m.mutex.Unlock()
+		return nil, &CommandError{Code: ErrCodeTooManyTopics, Message: "Too many subscriptions"}
+	}
+	m.trackPresence(&pending, c, cmd.Topic, func() { m.subscribe(c, cmd.Topic) })
+	m.mutex.Unlock()
+
+	m.publishPresence(pending)
+	return cmd, nil
+}
+
+// handleUnsubscribe 取消订阅，不能取消自己的 user 主题
+func (m *WSManager) handleUnsubscribe(c *Client, data json.RawMessage) (interface{}, error) {
+	var cmd topicCommand
+	if err := json.Unmarshal(data, &cmd); err != nil || cmd.Topic == "" {
+		return nil, &CommandError{Code: ErrCodeInvalidTopic, Message: "topic is required"}
+	}
+	if cmd.Topic == UserTopic(c.ID) {
+		return nil, &CommandError{Code: ErrCodeInvalidTopic, Message: "Cannot unsubscribe from own user topic"}
+	}
+
+	var pending []pendingPresence
+	m.mutex.Lock()
+	m.trackPresence(&pending, c, cmd.Topic, func() { m.unsubscribe(c, cmd.Topic) })
+	m.mutex.Unlock()
+
+	m.publishPresence(pending)
+	return cmd, nil
+}
+
+// subscribe / unsubscribe 调用方需持有写锁
+func (m *WSManager) subscribe(c *Client, topic string) {
+	if _, ok := m.topics[topic]; !ok {
+		m.topics[topic] = make(map[*Client]bool)
+	}
+	m.topics[topic][c] = true
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	c.topics[topic] = true
+}
+
+func (m *WSManager) unsubscribe(c *Client, topic string) {
+	if subscribers, ok := m.topics[topic]; ok {
+		delete(subscribers, c)
+		if len(subscribers) == 0 {
+			delete(m.topics, topic)
+		}
+	}
+	delete(c.topics, topic)
+	delete(c.heartbeats, topic)
+}
+
+// Publish 把事件推送给订阅了任一主题的客户端，同一客户端只收到一次；
+// 接入 backplane 时同时发给其他实例。推送过程记录为 ctx 下的 ws.publish span，
+// 写出到各连接时记录链接到它的 ws.deliver span
+func (m *WSManager) Publish(ctx context.Context, event interface{}, topics ...string) {
+	ctx, span := tracer.Start(ctx, "ws.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.StringSlice("ws.topics", topics)))
+	defer span.End()
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.ErrorContext(ctx, "Failed to marshal event", "error", err)
+		return
+	}
+	span.SetAttributes(attribute.Int("ws.recipients", m.publish(ctx, message, nil, topics...)))
+	m.forward(ctx, Envelope{Topics: topics, Payload: message})
+}
+
+// publish 推送给订阅者，exclude 不为空时跳过该客户端，返回推送的客户端数。事件同时记入 replay，供 SSE 断线续传；
+// ctx 中的 span 随消息传给写出的一方，作为 ws.deliver span 的链接
+func (m *WSManager) publish(ctx context.Context, message []byte, exclude *Client, topics ...string) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	m.replay.mutex.Lock()
+	defer m.replay.mutex.Unlock()
+	event := outbound{Seq: m.replay.append(topics, message), Data: message, frames: &frameCache{},
+		Span: trace.SpanContextFromContext(ctx)}
+
+	delivered := make(map[*Client]bool)
+	for _, topic := range topics {
+		for client := range m.topics[topic] {
+			if delivered[client] || client == exclude {
+				continue
+			}
+			delivered[client] = true
+
+			m.deliver(client, event)
+		}
+	}
+	return len(delivered)
+}